@@ -0,0 +1,166 @@
+package rvz
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// checkSize is how much of each compressed group is decoded by Check.
+const checkSize = 0x400
+
+func (r *Reader) checkRegions() error {
+	var offset int64
+
+	for _, rg := range r.regions() {
+		switch {
+		case rg.offset < offset:
+			return fmt.Errorf("%w at offset %#x", ErrRegionOverlap, rg.offset)
+		case rg.offset > offset:
+			return fmt.Errorf("%w at offset %#x", ErrRegionGap, offset)
+		}
+
+		// Partition groups cover the same number of sectors
+		chunkSize := r.disc.chunkSize(false)
+		if int64(rg.numGroup) != (rg.size+chunkSize-1)/chunkSize {
+			if rg.raw < 0 {
+				return fmt.Errorf("%w %d", ErrPartEntry, rg.part)
+			}
+
+			return fmt.Errorf("%w %d", ErrRawEntry, rg.raw)
+		}
+
+		offset += rg.size
+	}
+
+	if offset != int64(r.header.IsoFileSize) {
+		return fmt.Errorf("%w at offset %#x", ErrRegionGap, offset)
+	}
+
+	return nil
+}
+
+type extent struct {
+	offset, size int64
+	name         string
+	group        bool
+}
+
+func (r *Reader) checkExtents() error {
+	extents := []extent{
+		{0, int64(binary.Size(r.header)) + int64(r.header.DiscSize), "header", false},
+		{int64(r.disc.PartOff), int64(r.disc.NumPart) * int64(r.disc.PartSize), "partition table", false},
+		{int64(r.disc.RawDataOff), int64(r.disc.RawDataSize), "raw data table", false},
+		{int64(r.disc.GroupOff), int64(r.disc.GroupSize), "group table", false},
+	}
+
+	for i, g := range r.group {
+		if g.size() > 0 {
+			extents = append(extents, extent{g.offset(), g.size(), fmt.Sprintf("group %d", i), true})
+		}
+	}
+
+	sort.SliceStable(extents, func(i, j int) bool {
+		if extents[i].offset == extents[j].offset {
+			return extents[i].size < extents[j].size
+		}
+
+		return extents[i].offset < extents[j].offset
+	})
+
+	var prev *extent
+
+	for i := range extents {
+		e := &extents[i]
+		if e.size == 0 {
+			continue
+		}
+
+		if prev != nil && e.offset < prev.offset+prev.size {
+			// Identical groups are allowed to share the same data
+			if !e.group || !prev.group || e.offset != prev.offset || e.size != prev.size {
+				return fmt.Errorf("%w: %s and %s", ErrOverlap, prev.name, e.name)
+			}
+		}
+
+		prev = e
+	}
+
+	return nil
+}
+
+func (r *Reader) checkGroup(g int, buf []byte) (err error) {
+	group := r.group[g]
+
+	rc, err := r.decompressor(io.NewSectionReader(r.ra, group.offset(), group.size()))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := rc.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	n, err := io.ReadFull(rc, buf)
+	if errors.Is(err, io.ErrUnexpectedEOF) && n > 0 {
+		err = nil
+	}
+
+	return err
+}
+
+// Check validates the structure of the RVZ image read from ra, which should
+// be size bytes long, without decompressing the whole disc image. It
+// confirms the regions of the disc image are covered exactly once, that the
+// groups and tables don't overlap within the file and that every compressed
+// group can at least start to be decompressed. The options are the same as
+// for NewReader.
+func Check(ra io.ReaderAt, size int64, opts ...Option) error {
+	r := &Reader{ra: ra, ctx: context.Background()}
+
+	err := r.setOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	if err = r.readHeader(); err != nil {
+		return err
+	}
+
+	if uint64(size) != r.header.RvzFileSize {
+		return ErrFileSize
+	}
+
+	if err = r.readTables(); err != nil {
+		return err
+	}
+
+	if err = r.checkRegions(); err != nil {
+		return err
+	}
+
+	if err = r.checkExtents(); err != nil {
+		return err
+	}
+
+	buf := make([]byte, checkSize)
+
+	for i, g := range r.group {
+		if !g.compressed() || g.size() == 0 {
+			continue
+		}
+
+		if err = r.checkGroup(i, buf); err != nil {
+			offset, p := r.groupLocation(i)
+
+			return r.decodeError(err, offset, i, p)
+		}
+	}
+
+	return nil
+}
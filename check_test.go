@@ -0,0 +1,79 @@
+package rvz_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	var offset int64
+
+	tables := []struct {
+		name    string
+		method  uint32
+		mangle  func([]testGroup)
+		corrupt func([]byte) []byte
+		target  error
+	}{
+		{
+			name:   "OK",
+			method: methodZstd,
+		},
+		{
+			name: "Truncated",
+			corrupt: func(b []byte) []byte {
+				return b[:len(b)-1]
+			},
+			target: rvz.ErrFileSize,
+		},
+		{
+			name: "Overlap",
+			mangle: func(groups []testGroup) {
+				groups[1].Offset++
+			},
+			target: rvz.ErrOverlap,
+		},
+		{
+			name:   "Shared",
+			method: methodZstd,
+			mangle: func(groups []testGroup) {
+				groups[3] = groups[0]
+			},
+		},
+		{
+			name:   "Decode",
+			method: methodZstd,
+			mangle: func(groups []testGroup) {
+				offset = int64(groups[2].Offset) << 2
+			},
+			corrupt: func(b []byte) []byte {
+				copy(b[offset:], "garbage")
+
+				return b
+			},
+			target: rvz.ErrCorrupt,
+		},
+	}
+
+	for _, table := range tables {
+		b := (&testImage{method: table.method, iso: testISO(16), mangle: table.mangle}).build(t)
+		if table.corrupt != nil {
+			b = table.corrupt(b)
+		}
+
+		err := rvz.Check(bytes.NewReader(b), int64(len(b)))
+
+		if table.target == nil {
+			assert.NoError(t, err, table.name)
+
+			continue
+		}
+
+		assert.ErrorIs(t, err, table.target, table.name)
+	}
+}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
-	"github.com/bodgit/plumbing"
 	"github.com/bodgit/rvz"
+	"github.com/bodgit/rvz/junk"
+	"github.com/bodgit/rvz/wii"
 	"github.com/schollz/progressbar/v3"
 	"github.com/urfave/cli/v2"
 )
@@ -31,6 +40,258 @@ func init() {
 	}
 }
 
+// sparseFile flushes the SparseWriter before closing the file.
+type sparseFile struct {
+	*rvz.SparseWriter
+	f *os.File
+}
+
+func (sf *sparseFile) Close() error {
+	if err := sf.Flush(); err != nil {
+		_ = sf.f.Close()
+
+		return err
+	}
+
+	return sf.f.Close()
+}
+
+func createFile(name string, sparse bool) (io.WriteCloser, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if sparse {
+		return &sparseFile{rvz.NewSparseWriter(f), f}, nil
+	}
+
+	return f, nil
+}
+
+// source is the disc image being decompressed, either a Reader or a
+// Scrubber.
+type source interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	WriteToContext(ctx context.Context, w io.Writer) (int64, error)
+}
+
+// outputRange returns the offset and length of the slice of the disc image
+// to output, which is the whole disc image unless --offset or --length are
+// set.
+func outputRange(c *cli.Context, size int64) (int64, int64, error) {
+	return parseRange(c.Int64("offset"), c.Int64("length"), c.Bool("sectors"), size)
+}
+
+// parseRange checks the offset and length of a slice of a disc image of size
+// bytes, counted in 32 KiB sectors if sectors is set. A length of zero, or
+// one that runs past the end, means up to the end of the disc image.
+func parseRange(offset, length int64, sectors bool, size int64) (int64, int64, error) {
+	if offset < 0 || offset > size {
+		return 0, 0, fmt.Errorf("offset %#x is outside of the disc image", offset)
+	}
+
+	if length < 0 {
+		return 0, 0, fmt.Errorf("invalid length %#x", length)
+	}
+
+	if sectors {
+		// Check the sectors fit in the disc image before converting
+		// them so that they can't overflow
+		if offset > size/wii.SectorSize {
+			return 0, 0, fmt.Errorf("sector %#x is outside of the disc image", offset)
+		}
+
+		offset *= wii.SectorSize
+
+		if length > (size-offset)/wii.SectorSize {
+			length = 0
+		}
+
+		length *= wii.SectorSize
+	}
+
+	if length == 0 || length > size-offset {
+		length = size - offset
+	}
+
+	return offset, length, nil
+}
+
+// writeOutput writes length bytes of src from offset to w. If that is the
+// rest of the disc image then groups are decoded in parallel, otherwise
+// only the groups covering the range are decoded, one at a time.
+func writeOutput(ctx context.Context, w io.Writer, src source, offset, length, size int64) error {
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	if offset+length == size {
+		_, err := src.WriteToContext(ctx, w)
+
+		return err
+	}
+
+	_, err := io.Copy(w, io.LimitReader(src, length))
+
+	return err
+}
+
+// resumeCheck is how much of the end of an interrupted output is checked
+// before carrying on.
+const resumeCheck = 4 << 20
+
+// resumeOutput opens an interrupted output of a disc image of size bytes and
+// positions both it and src where decompression should carry on.
+func resumeOutput(c *cli.Context, name string, src source, size int64) (io.WriteCloser, error) {
+	if c.Bool("split") || c.IsSet("split-size") || c.IsSet("split-naming") || c.Bool("sparse") {
+		return nil, errors.New("--resume can't be combined with splitting or sparse output")
+	}
+
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := resumeOffset(f, src, size)
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	fmt.Fprintf(c.App.Writer, "%s: resuming at %#x\n", name, offset)
+
+	return f, nil
+}
+
+func resumeOffset(f *os.File, src source, size int64) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := rvz.ResumeOffset(src, f, size, fi.Size(), resumeCheck)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = f.Truncate(offset); err != nil {
+		return 0, err
+	}
+
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return src.Seek(offset, io.SeekStart)
+}
+
+// openOutput opens the file or files that size bytes of the disc image were
+// written to. Only as many parts as are needed for size bytes are opened, so
+// any left over from an earlier, larger output are ignored.
+func openOutput(c *cli.Context, name string, size int64) (io.Reader, []io.Closer, error) {
+	if !c.Bool("split") && !c.IsSet("split-size") && !c.IsSet("split-naming") {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return f, []io.Closer{f}, nil
+	}
+
+	naming, err := splitNaming(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		readers []io.Reader
+		closers []io.Closer
+	)
+
+	for i, n := 0, int64(0); i == 0 || n < size; i, n = i+1, n+c.Int64("split-size") {
+		f, err := os.Open(naming.Name(name, i))
+		if err != nil {
+			for _, c := range closers {
+				c.Close()
+			}
+
+			return nil, nil, err
+		}
+
+		readers = append(readers, f)
+		closers = append(closers, f)
+	}
+
+	return io.MultiReader(readers...), closers, nil
+}
+
+// verifyOutput checks that the hash of what was written matches the hash of
+// the disc image, which catches any damage to an output that was resumed.
+func verifyOutput(ctx context.Context, c *cli.Context, name string, src source, offset, length, size int64) error {
+	r, closers, err := openOutput(c, name, length)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}()
+
+	got := sha1.New() //nolint:gosec
+	if _, err = io.Copy(got, r); err != nil {
+		return err
+	}
+
+	want := sha1.New() //nolint:gosec
+	if err = writeOutput(ctx, want, src, offset, length, size); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(got.Sum(nil), want.Sum(nil)) {
+		return fmt.Errorf("%s: output doesn't match the disc image", name)
+	}
+
+	fmt.Fprintf(c.App.Writer, "%s: OK\n", name)
+
+	return nil
+}
+
+func splitNaming(c *cli.Context) (rvz.SplitNaming, error) {
+	switch c.String("split-naming") {
+	case "part":
+		return rvz.SplitPart, nil
+	case "suffix":
+		return rvz.SplitSuffix, nil
+	default:
+		return 0, fmt.Errorf("unknown split naming scheme %q", c.String("split-naming"))
+	}
+}
+
+// createOutput creates the file or files that the disc image is written to.
+func createOutput(c *cli.Context, name string) (io.WriteCloser, error) {
+	sparse := c.Bool("sparse")
+
+	if !c.Bool("split") && !c.IsSet("split-size") && !c.IsSet("split-naming") {
+		return createFile(name, sparse)
+	}
+
+	naming, err := splitNaming(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return rvz.NewSplitWriter(c.Int64("split-size"), func(i int) (io.WriteCloser, error) {
+		return createFile(naming.Name(name, i), sparse)
+	})
+}
+
+//nolint:cyclop,funlen
 func decompress(c *cli.Context) error {
 	if c.NArg() < 1 {
 		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
@@ -51,28 +312,588 @@ func decompress(c *cli.Context) error {
 	}
 	defer f.Close()
 
-	r, err := rvz.NewReader(f)
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+	defer stop()
+
+	var opts []rvz.Option
+
+	if c.Bool("verbose") {
+		var pb *progressbar.ProgressBar
+
+		opts = append(opts, rvz.WithProgress(func(p rvz.Progress) {
+			if pb == nil {
+				pb = progressbar.DefaultBytes(p.Size)
+			}
+
+			if p.Partition < 0 {
+				pb.Describe("raw data")
+			} else {
+				pb.Describe(fmt.Sprintf("partition %d", p.Partition))
+			}
+
+			_ = pb.Set64(p.Offset)
+		}))
+	}
+
+	r, err := rvz.NewReaderContext(ctx, f, opts...)
 	if err != nil {
 		return err
 	}
+	defer r.Close()
 
-	var w io.WriteCloser
+	var (
+		image source = r
+		s     *rvz.Scrubber
+	)
+
+	if c.Bool("scrub") {
+		if s, err = rvz.NewScrubber(r); err != nil {
+			return err
+		}
+
+		image = s
+	}
 
-	w, err = os.Create(dst)
+	size := r.Size()
+
+	offset, length, err := outputRange(c, size)
 	if err != nil {
 		return err
 	}
 
-	if c.Bool("verbose") {
-		pb := progressbar.DefaultBytes(r.Size())
-		w = plumbing.MultiWriteCloser(w, plumbing.NopWriteCloser(pb))
+	var w io.WriteCloser
+
+	switch {
+	case c.Bool("resume") && length != size:
+		return errors.New("--resume can't be combined with --offset or --length")
+	case c.Bool("resume"):
+		w, err = resumeOutput(c, dst, image, size)
+		offset = 0
+	default:
+		w, err = createOutput(c, dst)
 	}
 
+	if err != nil {
+		return err
+	}
 	defer w.Close()
 
-	_, err = io.Copy(w, r)
+	if c.Bool("resume") {
+		_, err = image.WriteToContext(ctx, w)
+	} else {
+		err = writeOutput(ctx, w, image, offset, length, size)
+	}
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	if s != nil {
+		fmt.Fprintf(c.App.Writer, "%s: scrubbed %d bytes\n", dst, s.ScrubbedRange(offset, length))
+	}
+
+	if c.Bool("verify-output") {
+		return verifyOutput(ctx, c, dst, image, offset, length, size)
+	}
+
+	return nil
+}
+
+func checkFile(src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return rvz.Check(f, fi.Size())
+}
+
+func check(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	var failed int
+
+	for _, src := range c.Args().Slice() {
+		if err := checkFile(src); err != nil {
+			fmt.Fprintf(c.App.ErrWriter, "%s: %v\n", src, err)
+
+			failed++
+
+			continue
+		}
+
+		if c.Bool("verbose") {
+			fmt.Fprintf(c.App.Writer, "%s: OK\n", src)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d images failed checks", failed, c.NArg())
+	}
+
+	return nil
+}
+
+func verifyFile(c *cli.Context, src string, keys wii.CommonKeys) (bool, error) {
+	r, err := rvz.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	mismatches, err := r.VerifyPartitions()
+	if err != nil {
+		return false, err
+	}
+
+	for _, m := range mismatches {
+		if m.Cluster < 0 {
+			fmt.Fprintf(c.App.ErrWriter, "%s: partition %d: H3 table at %#x doesn't match TMD\n",
+				src, m.Partition, m.Offset)
+
+			continue
+		}
+
+		fmt.Fprintf(c.App.ErrWriter, "%s: partition %d: cluster %d at %#x doesn't match H3 table\n",
+			src, m.Partition, m.Cluster, m.Offset)
+	}
+
+	ok := len(mismatches) == 0
+
+	if keys != nil {
+		if err = r.VerifyTitleKeys(keys); err != nil {
+			if !errors.Is(err, rvz.ErrTitleKey) {
+				return false, err
+			}
+
+			fmt.Fprintf(c.App.ErrWriter, "%s: %v\n", src, err)
+
+			ok = false
+		}
+	}
+
+	return ok, nil
+}
+
+func verify(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	var keys wii.CommonKeys
+
+	if file := c.Path("common-key"); file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		if keys, err = wii.ParseCommonKeys(b); err != nil {
+			return err
+		}
+	}
+
+	var failed int
+
+	for _, src := range c.Args().Slice() {
+		ok, err := verifyFile(c, src, keys)
+		if err != nil {
+			fmt.Fprintf(c.App.ErrWriter, "%s: %v\n", src, err)
+		}
+
+		if !ok {
+			failed++
+
+			continue
+		}
+
+		if c.Bool("verbose") {
+			fmt.Fprintf(c.App.Writer, "%s: OK\n", src)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d images failed verification", failed, c.NArg())
+	}
+
+	return nil
+}
+
+func signatureStatus(err error, root *rsa.PublicKey) string {
+	switch {
+	case errors.Is(err, wii.ErrFakesigned):
+		return "fakesigned"
+	case err != nil:
+		return err.Error()
+	case root == nil:
+		return "valid (root not checked)"
+	}
+
+	return "valid"
+}
+
+func partitions(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	var root *rsa.PublicKey
+
+	if file := c.Path("root-key"); file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		if root, err = wii.ParseRootKey(b); err != nil {
+			return err
+		}
+	}
+
+	r, err := rvz.Open(c.Args().First())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	parts, err := r.Partitions()
+	if err != nil {
+		return err
+	}
+
+	for i, p := range parts {
+		h := p.Header
+
+		fmt.Fprintf(c.App.Writer, "partition %d at %#x, type %d: title %s, IOS%d, %d content(s)\n",
+			i, p.Offset, p.Type, h.TMD.TitleID, h.TMD.IOSVersion(), len(h.TMD.Contents))
+		fmt.Fprintf(c.App.Writer, "  ticket: %s\n", signatureStatus(h.Ticket.Verify(h.Certificates, root), root))
+		fmt.Fprintf(c.App.Writer, "  TMD: %s\n", signatureStatus(h.TMD.Verify(h.Certificates, root), root))
+	}
+
+	return nil
+}
+
+func writeStats(w io.Writer, name string, gs *rvz.GroupStats) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n", name, gs.Groups, gs.Zero, gs.Stored,
+		gs.Compressed, gs.Packed, gs.Size, gs.FileSize, gs.Junk)
+}
+
+func stats(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	for i, src := range c.Args().Slice() {
+		r, err := rvz.Open(src)
+		if err != nil {
+			return err
+		}
+
+		s, err := r.Stats()
+		r.Close()
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", src, err)
+		}
+
+		if i > 0 {
+			fmt.Fprintln(c.App.Writer)
+		}
+
+		fmt.Fprintf(c.App.Writer, "%s:\n", src)
+
+		tw := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "region\tgroups\tzero\tstored\tcompressed\tpacked\tsize\tfile size\tjunk\t")
+
+		for _, rs := range s.Regions {
+			name := fmt.Sprintf("%s %#x-%#x", rs.Region.Kind, rs.Region.Offset, rs.Region.End)
+			if rs.Region.Partition >= 0 {
+				name = fmt.Sprintf("%s (partition %d)", name, rs.Region.Partition)
+			}
+
+			writeStats(tw, name, &rs.GroupStats)
+		}
+
+		writeStats(tw, "total", &s.Total)
+
+		if err = tw.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(c.App.Writer, "compression ratios:")
+
+		for j, n := range s.Total.Ratios {
+			if j == rvz.RatioBuckets-1 {
+				fmt.Fprintf(c.App.Writer, "  %8s: %d\n", ">=100%", n)
+			} else {
+				fmt.Fprintf(c.App.Writer, "  %3d-%3d%%: %d\n", j*10, (j+1)*10, n)
+			}
+		}
+	}
+
+	return nil
+}
+
+func groups(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	r, err := rvz.Open(c.Args().First())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tw := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "group\tdisc offset\tfile offset\tsize\tcompressed\tpacked size\tregion\t")
+
+	it := r.Groups()
+	for it.Next() {
+		g := it.Group()
+		fmt.Fprintf(tw, "%d\t%#x\t%#x\t%d\t%v\t%d\t%s\t\n",
+			g.Index, g.DiscOffset, g.Offset, g.Size, g.Compressed, g.PackedSize, g.Region.Kind)
+	}
+
+	if err = it.Err(); err != nil {
+		return err
+	}
+
+	return tw.Flush()
+}
+
+func writeExceptions(name string, exceptions []rvz.HashException) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, e := range exceptions {
+		if _, err = fmt.Fprintf(f, "%#04x %x\n", e.Offset, e.Hash); err != nil {
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+func dumpGroup(c *cli.Context) error {
+	if c.NArg() != 2 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	src := c.Args().Get(0)
+
+	g, err := strconv.Atoi(c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	r, err := rvz.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	gd, derr := r.DumpGroup(g)
+	if gd == nil {
+		return derr
+	}
+
+	base := filepath.Join(c.Path("output"), fmt.Sprintf("%s.group%d", filepath.Base(src), g))
+
+	if err = os.WriteFile(base+".raw", gd.Raw, 0o644); err != nil {
+		return err
+	}
+
+	if err = os.WriteFile(base+".bin", gd.Data, 0o644); err != nil {
+		return err
+	}
+
+	if gd.Group.Region.Kind == rvz.PartitionData {
+		if err = writeExceptions(base+".exceptions", gd.Exceptions); err != nil {
+			return err
+		}
+	}
+
+	return derr
+}
+
+// openImage opens src as an RVZ image, or failing that as a plain disc
+// image.
+func openImage(src string) (io.ReadCloser, error) {
+	r, err := rvz.Open(src)
+	if err == nil {
+		return r, nil
+	}
+
+	if !errors.Is(err, rvz.ErrBadMagic) {
+		return nil, err
+	}
+
+	return os.Open(src)
+}
+
+// A junkArea is part of a disc image scanned for junk data on its own, either
+// the disc outside of the Wii partitions or the decrypted data of one of them.
+type junkArea struct {
+	name string
+	m    *junk.Map
+}
+
+// scanPartition scans the decrypted data of Wii partition p for junk data,
+// using the game ID and disc number from the partition's own header.
+func scanPartition(r *rvz.Reader, p int) (*junk.Map, error) {
+	pr, err := r.OpenPartition(p)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.Close()
+
+	header := make([]byte, junk.HeaderSize)
+	if _, err = io.ReadFull(pr, header); err != nil {
+		return nil, err
+	}
+
+	d, err := junk.ParseDisc(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return junk.Scan(io.MultiReader(bytes.NewReader(header), pr), d, 0)
+}
+
+// scanWii scans a Wii disc for junk data, first the disc outside of the
+// partitions and then the decrypted data of each partition.
+func scanWii(r *rvz.Reader, d junk.Disc) ([]junkArea, error) {
+	regions, err := r.Layout()
+	if err != nil {
+		return nil, err
+	}
+
+	disc := &junk.Map{}
+	areas := []junkArea{{"disc", disc}}
+
+	for _, rg := range regions {
+		if rg.Kind == rvz.PartitionData {
+			if areas[len(areas)-1].name == fmt.Sprintf("partition %d", rg.Partition) {
+				continue
+			}
+
+			m, err := scanPartition(r, rg.Partition)
+			if err != nil {
+				return nil, fmt.Errorf("partition %d: %w", rg.Partition, err)
+			}
+
+			areas = append(areas, junkArea{fmt.Sprintf("partition %d", rg.Partition), m})
+
+			continue
+		}
+
+		m, err := junk.Scan(io.NewSectionReader(r, rg.Offset, rg.Size()), d, rg.Offset)
+		if err != nil {
+			return nil, err
+		}
+
+		disc.Ranges = append(disc.Ranges, m.Ranges...)
+		disc.Size += m.Size
+	}
+
+	return areas, nil
+}
+
+func scanJunk(src string) ([]junkArea, error) {
+	r, err := openImage(src)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	header := make([]byte, junk.HeaderSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	d, err := junk.ParseDisc(header)
+	if err != nil {
+		return nil, err
+	}
+
+	if junk.IsWii(header) {
+		rr, ok := r.(*rvz.Reader)
+		if !ok {
+			return nil, errors.New("the partitions of a Wii disc can only be scanned in an RVZ image")
+		}
+
+		return scanWii(rr, d)
+	}
+
+	m, err := junk.Scan(io.MultiReader(bytes.NewReader(header), r), d, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return []junkArea{{"disc", m}}, nil
+}
+
+func printRanges(w io.Writer, m *junk.Map, indent string) {
+	for _, rg := range m.Ranges {
+		fmt.Fprintf(w, "%s%#x-%#x\n", indent, rg.Offset, rg.End())
+	}
+}
+
+func findJunk(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	for _, src := range c.Args().Slice() {
+		areas, err := scanJunk(src)
+		if err != nil {
+			return fmt.Errorf("%s: %w", src, err)
+		}
+
+		total := &junk.Map{}
+
+		for _, a := range areas {
+			total.Ranges = append(total.Ranges, a.m.Ranges...)
+			total.Size += a.m.Size
+		}
+
+		fmt.Fprintf(c.App.Writer, "%s: %d of %d bytes are junk (%.2f%%)\n", src, total.Junk(), total.Size, total.Percent())
+
+		if len(areas) == 1 {
+			if c.Bool("map") {
+				printRanges(c.App.Writer, total, "  ")
+			}
+
+			continue
+		}
+
+		// Offsets in a partition count from the start of its decrypted data
+		for _, a := range areas {
+			fmt.Fprintf(c.App.Writer, "  %s: %d of %d bytes are junk (%.2f%%)\n", a.name, a.m.Junk(), a.m.Size, a.m.Percent())
+
+			if c.Bool("map") {
+				printRanges(c.App.Writer, a.m, "    ")
+			}
+		}
+	}
+
+	return nil
 }
 
 func main() {
@@ -94,9 +915,147 @@ func main() {
 					Aliases: []string{"v"},
 					Usage:   "increase verbosity",
 				},
+				&cli.BoolFlag{
+					Name:  "scrub",
+					Usage: "fill junk data and other unused areas with zeroes",
+				},
+				&cli.BoolFlag{
+					Name:  "sparse",
+					Usage: "seek over runs of zeroes to create a sparse file",
+				},
+				&cli.BoolFlag{
+					Name:  "split",
+					Usage: "split the output into parts that fit on a FAT32 filesystem",
+				},
+				&cli.Int64Flag{
+					Name:  "split-size",
+					Value: rvz.FAT32SplitSize,
+					Usage: "split the output into parts of `SIZE` bytes, a multiple of 32 KiB",
+				},
+				&cli.StringFlag{
+					Name:  "split-naming",
+					Value: "part",
+					Usage: "name the parts using `SCHEME`, either part (image.part0.iso) or suffix (image.iso, image.iso.1)",
+				},
+				&cli.BoolFlag{
+					Name:  "resume",
+					Usage: "carry on from where an interrupted decompression stopped",
+				},
+				&cli.BoolFlag{
+					Name:  "verify-output",
+					Usage: "check the hash of the output matches the disc image once finished",
+				},
+				&cli.Int64Flag{
+					Name:  "offset",
+					Usage: "only output the disc image from `OFFSET` onwards",
+				},
+				&cli.Int64Flag{
+					Name:  "length",
+					Usage: "only output `LENGTH` bytes of the disc image",
+				},
+				&cli.BoolFlag{
+					Name:  "sectors",
+					Usage: "count --offset and --length in 32 KiB sectors",
+				},
 			},
 			Action: decompress,
 		},
+		{
+			Name:        "check",
+			Usage:       "Check structure of RVZ images",
+			Description: "Check the structure of RVZ images without fully decompressing them",
+			ArgsUsage:   "SOURCE...",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:    "verbose",
+					Aliases: []string{"v"},
+					Usage:   "increase verbosity",
+				},
+			},
+			Action: check,
+		},
+		{
+			Name:        "verify",
+			Usage:       "Verify Wii partition hashes in RVZ images",
+			Description: "Verify the data in each Wii partition against its H3 table and TMD",
+			ArgsUsage:   "SOURCE...",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:    "verbose",
+					Aliases: []string{"v"},
+					Usage:   "increase verbosity",
+				},
+				&cli.PathFlag{
+					Name:  "common-key",
+					Usage: "check title keys using the common keys in `FILE`",
+				},
+			},
+			Action: verify,
+		},
+		{
+			Name:        "partitions",
+			Usage:       "List Wii partitions in an RVZ image",
+			Description: "List each Wii partition with its title and the status of the ticket and TMD signatures",
+			ArgsUsage:   "SOURCE",
+			Flags: []cli.Flag{
+				&cli.PathFlag{
+					Name:  "root-key",
+					Usage: "check signatures against the root public key in `FILE`",
+				},
+			},
+			Action: partitions,
+		},
+		{
+			Name:        "stats",
+			Usage:       "Show group statistics of RVZ images",
+			Description: "Summarise how the groups in each region of RVZ images are stored, including how much junk data was replaced by padding seeds",
+			ArgsUsage:   "SOURCE...",
+			Action:      stats,
+		},
+		{
+			Name:  "junk",
+			Usage: "Find junk data in disc images",
+			Description: "Scan RVZ or ISO images for the junk data generated from the game ID and disc number. " +
+				"The junk data in each Wii partition is found by decrypting it, which needs an RVZ image",
+			ArgsUsage: "SOURCE...",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:    "map",
+					Aliases: []string{"m"},
+					Usage:   "list each range of junk data",
+				},
+			},
+			Action: findJunk,
+		},
+		{
+			Name:  "debug",
+			Usage: "Inspect the internals of an RVZ image",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "groups",
+					Usage:       "List the groups of an RVZ image",
+					Description: "List each group in disc order with where it is stored and how",
+					ArgsUsage:   "SOURCE",
+					Action:      groups,
+				},
+				{
+					Name:  "dump-group",
+					Usage: "Dump a single group of an RVZ image",
+					Description: "Write the raw and decompressed bytes of group N to SOURCE.groupN.raw and SOURCE.groupN.bin, " +
+						"along with the hash exceptions of Wii partition data to SOURCE.groupN.exceptions",
+					ArgsUsage: "SOURCE N",
+					Flags: []cli.Flag{
+						&cli.PathFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Value:   ".",
+							Usage:   "write the files to `DIR`",
+						},
+					},
+					Action: dumpGroup,
+				},
+			},
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//nolint:funlen
+func TestParseRange(t *testing.T) {
+	t.Parallel()
+
+	const size = 10*0x8000 + 0x100
+
+	tables := map[string]struct {
+		offset, length int64
+		sectors        bool
+		wantOffset     int64
+		wantLength     int64
+		err            bool
+	}{
+		"whole": {
+			wantLength: size,
+		},
+		"boot header": {
+			length:     0x440,
+			wantLength: 0x440,
+		},
+		"zero length": {
+			offset:     0x1000,
+			wantOffset: 0x1000,
+			wantLength: size - 0x1000,
+		},
+		"past the end": {
+			offset:     size - 0x10,
+			length:     0x100,
+			wantOffset: size - 0x10,
+			wantLength: 0x10,
+		},
+		"at the end": {
+			offset:     size,
+			wantOffset: size,
+		},
+		"offset past the end": {
+			offset: size + 1,
+			err:    true,
+		},
+		"negative offset": {
+			offset: -1,
+			err:    true,
+		},
+		"negative length": {
+			length: -1,
+			err:    true,
+		},
+		"huge length": {
+			offset:     0x100,
+			length:     math.MaxInt64,
+			wantOffset: 0x100,
+			wantLength: size - 0x100,
+		},
+		"sectors": {
+			offset:     2,
+			length:     3,
+			sectors:    true,
+			wantOffset: 2 * 0x8000,
+			wantLength: 3 * 0x8000,
+		},
+		"sectors past the end": {
+			offset:     9,
+			length:     3,
+			sectors:    true,
+			wantOffset: 9 * 0x8000,
+			wantLength: 0x8100,
+		},
+		"sector offset past the end": {
+			offset:  11,
+			sectors: true,
+			err:     true,
+		},
+		"sector offset overflow": {
+			offset:  math.MaxInt64 / 0x8000 * 2,
+			sectors: true,
+			err:     true,
+		},
+		"sector length overflow": {
+			offset:     1,
+			length:     math.MaxInt64/0x8000 + 1,
+			sectors:    true,
+			wantOffset: 0x8000,
+			wantLength: size - 0x8000,
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			offset, length, err := parseRange(table.offset, table.length, table.sectors, size)
+			if table.err {
+				assert.Error(t, err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, table.wantOffset, offset)
+			assert.Equal(t, table.wantLength, length)
+		})
+	}
+}
+
+// testSource is a disc image held in memory that records whether WriteTo
+// was used.
+type testSource struct {
+	*bytes.Reader
+	writeTo bool
+}
+
+func (ts *testSource) WriteToContext(_ context.Context, w io.Writer) (int64, error) {
+	ts.writeTo = true
+
+	return ts.Reader.WriteTo(w)
+}
+
+func TestWriteOutput(t *testing.T) {
+	t.Parallel()
+
+	b := make([]byte, 0x10000)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+
+	tables := map[string]struct {
+		offset, length int64
+		writeTo        bool
+	}{
+		"whole":    {0, int64(len(b)), true},
+		"to end":   {0x1234, int64(len(b)) - 0x1234, true},
+		"slice":    {0x440, 0x100, false},
+		"start":    {0, 0x440, false},
+		"empty":    {int64(len(b)), 0, true},
+		"one byte": {0xffff, 1, true},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			src := &testSource{Reader: bytes.NewReader(b)}
+
+			// Start somewhere else
+			_, _ = src.Seek(0x10, io.SeekStart)
+
+			buf := new(bytes.Buffer)
+			assert.NoError(t, writeOutput(context.Background(), buf, src, table.offset, table.length, int64(len(b))))
+			assert.True(t, bytes.Equal(b[table.offset:table.offset+table.length], buf.Bytes()))
+			assert.Equal(t, table.writeTo, src.writeTo)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package rvz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrCorrupt is matched by every error caused by an image that is
+	// damaged or not a valid RVZ file.
+	ErrCorrupt = errors.New("rvz: corrupt image")
+
+	// ErrUnsupported is matched by every error caused by a valid image
+	// using a feature this package doesn't handle.
+	ErrUnsupported = errors.New("rvz: unsupported feature")
+
+	// ErrOption is returned when an Option is given an invalid value.
+	ErrOption = errors.New("rvz: invalid option")
+
+	// ErrClosed is returned when a Reader is used after it has been
+	// closed.
+	ErrClosed = errors.New("rvz: reader is closed")
+
+	// ErrGroupIndex is returned when a group is requested that isn't in
+	// the group table.
+	ErrGroupIndex = errors.New("rvz: group index out of range")
+
+	// ErrSectorIndex is returned when a sector is requested that isn't in
+	// a Wii partition.
+	ErrSectorIndex = errors.New("rvz: sector index out of range")
+
+	// ErrSectorFormat is returned when ReadSector is asked for a
+	// SectorFormat that doesn't exist.
+	ErrSectorFormat = errors.New("rvz: unknown sector format")
+
+	// ErrOffset is returned when seeking or reading from a negative
+	// offset in the disc image.
+	ErrOffset = errors.New("rvz: invalid offset")
+
+	// ErrSplitSize is returned when the size of each part of a split disc
+	// image isn't a positive multiple of the 32 KiB sector size.
+	ErrSplitSize = errors.New("rvz: split size must be a multiple of the sector size")
+
+	// ErrResumeSize is returned by ResumeOffset when more has been
+	// written than the size of the disc image.
+	ErrResumeSize = errors.New("rvz: partial image is larger than the disc image")
+)
+
+type corruptError string
+
+func (e corruptError) Error() string {
+	return "rvz: " + string(e)
+}
+
+func (corruptError) Is(target error) bool {
+	return target == ErrCorrupt //nolint:errorlint
+}
+
+type unsupportedError string
+
+func (e unsupportedError) Error() string {
+	return "rvz: " + string(e)
+}
+
+func (unsupportedError) Is(target error) bool {
+	return target == ErrUnsupported //nolint:errorlint
+}
+
+// Errors returned when the image is damaged or not an RVZ file. Each of them
+// also matches ErrCorrupt.
+var (
+	ErrBadMagic       error = corruptError("bad magic")
+	ErrHeaderHash     error = corruptError("header hash doesn't match")
+	ErrDiscSize       error = corruptError("disc struct has wrong size")
+	ErrDiscHash       error = corruptError("disc hash doesn't match")
+	ErrDiscType       error = corruptError("invalid disc type")
+	ErrChunkSize      error = corruptError("bad chunk size")
+	ErrPartSize       error = corruptError("part struct has wrong size")
+	ErrPartHash       error = corruptError("partition hash doesn't match")
+	ErrImageSize      error = corruptError("image size out of range")
+	ErrComprData      error = corruptError("bad compressor data length")
+	ErrTableSize      error = corruptError("too many table entries")
+	ErrTableBounds    error = corruptError("table outside of file")
+	ErrRawEntry       error = corruptError("bad raw data entry")
+	ErrPartEntry      error = corruptError("bad partition entry")
+	ErrGroupEntry     error = corruptError("bad group entry")
+	ErrNoRegion       error = corruptError("cannot find region")
+	ErrShortGroup     error = corruptError("group is too short")
+	ErrFileSize       error = corruptError("file size doesn't match header")
+	ErrRegionGap      error = corruptError("disc image not covered")
+	ErrRegionOverlap  error = corruptError("regions overlap")
+	ErrOverlap        error = corruptError("data overlaps")
+	ErrPartitionTable error = corruptError("bad partition table")
+	ErrNoPartition    error = corruptError("cannot find partition")
+	ErrTMDSize        error = corruptError("TMD has no contents")
+	ErrTitleKey       error = corruptError("title key doesn't match ticket for partition")
+	ErrFileSystem     error = corruptError("bad file system table")
+)
+
+// Errors returned when the image uses a feature that isn't handled. Each of
+// them also matches ErrUnsupported.
+var (
+	ErrHashExceptions error = unsupportedError("hash exceptions")
+)
+
+// dataError wraps an error from outside the package that was caused by bad
+// data, such as a Decompressor rejecting the compressed data or a malformed
+// Wii partition header.
+type dataError struct {
+	err error
+}
+
+func (e *dataError) Error() string {
+	return e.err.Error()
+}
+
+func (e *dataError) Unwrap() error {
+	return e.err
+}
+
+func (*dataError) Is(target error) bool {
+	return target == ErrCorrupt //nolint:errorlint
+}
+
+// UnsupportedMethodError is returned when the image uses a compression method
+// that has no registered Decompressor. It matches ErrUnsupported.
+type UnsupportedMethodError uint32
+
+func (e UnsupportedMethodError) Error() string {
+	return fmt.Sprintf("rvz: unsupported algorithm %d", uint32(e))
+}
+
+// Is reports whether target is ErrUnsupported.
+func (UnsupportedMethodError) Is(target error) bool {
+	return target == ErrUnsupported //nolint:errorlint
+}
+
+// DecodeError records where in the image a group failed to decode.
+type DecodeError struct {
+	// Offset is the offset in the uncompressed disc image where the
+	// failing read started.
+	Offset int64
+	// Group is the index of the group being decoded, or -1 if the
+	// failure isn't tied to a group.
+	Group int
+	// Partition is the index of the Wii partition being decoded, or -1
+	// if the failure happened in a raw data region.
+	Partition int
+	// Method is the compression method used by the image.
+	Method uint32
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	var b strings.Builder
+
+	b.WriteString("rvz: ")
+
+	if e.Group >= 0 {
+		fmt.Fprintf(&b, "group %d, ", e.Group)
+	}
+
+	if e.Partition >= 0 {
+		fmt.Fprintf(&b, "partition %d, ", e.Partition)
+	}
+
+	fmt.Fprintf(&b, "offset %#x: %s", e.Offset, strings.TrimPrefix(e.Err.Error(), "rvz: "))
+
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
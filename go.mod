@@ -5,6 +5,7 @@ go 1.19
 require (
 	github.com/bodgit/plumbing v1.3.0
 	github.com/bodgit/rom v0.0.1
+	github.com/dsnet/compress v0.0.1
 	github.com/klauspost/compress v1.17.7
 	github.com/schollz/progressbar/v3 v3.14.2
 	github.com/stretchr/testify v1.11.1
@@ -0,0 +1,211 @@
+package rvz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// A Group describes one entry of the group table.
+type Group struct {
+	// Index is the index of the group in the group table.
+	Index int
+	// Offset and Size are where the group's data is stored in the image.
+	// A Size of zero means the group is all zeroes.
+	Offset, Size int64
+	// Compressed is set if the data is compressed.
+	Compressed bool
+	// PackedSize is the size of the data before RVZ packing is undone, or
+	// zero if the group isn't packed.
+	PackedSize int64
+	// DiscOffset is where the group starts in the disc image, or -1 if
+	// no region uses it.
+	DiscOffset int64
+	// Region is the region of the disc image that the group starts in.
+	Region Region
+}
+
+// A GroupIterator steps through the groups of an image in the order they
+// appear in the disc image.
+type GroupIterator struct {
+	groups []Group
+	i      int
+	err    error
+}
+
+// Next advances to the next group, returning false when there are no more
+// groups or an error occurred.
+func (it *GroupIterator) Next() bool {
+	if it.err != nil || it.i >= len(it.groups) {
+		return false
+	}
+
+	it.i++
+
+	return true
+}
+
+// Group returns the current group.
+func (it *GroupIterator) Group() Group {
+	return it.groups[it.i-1]
+}
+
+// Err returns any error that stopped the iteration.
+func (it *GroupIterator) Err() error {
+	return it.err
+}
+
+// groupEntry returns the group table entry for group g, without its
+// location in the disc image.
+func (r *Reader) groupEntry(g int) Group {
+	group := &r.group[g]
+
+	return Group{
+		Index:      g,
+		Offset:     group.offset(),
+		Size:       group.size(),
+		Compressed: group.compressed(),
+		PackedSize: int64(group.PackedSize),
+		DiscOffset: -1,
+		Region:     Region{Partition: -1},
+	}
+}
+
+// groups returns every group in the order they appear in the disc image.
+func (r *Reader) groups() ([]Group, error) {
+	layout, parents, err := r.layout()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []Group
+
+	for i, region := range layout {
+		region := region
+
+		r.ownGroups(parents[i], region, func(g int, start int64) {
+			group := r.groupEntry(g)
+			group.DiscOffset = start
+			group.Region = region
+
+			groups = append(groups, group)
+		})
+	}
+
+	return groups, nil
+}
+
+// Groups returns an iterator over the groups of the image.
+func (r *Reader) Groups() *GroupIterator {
+	if err := r.err(); err != nil {
+		return &GroupIterator{err: err}
+	}
+
+	groups, err := r.groups()
+
+	return &GroupIterator{groups: groups, err: err}
+}
+
+// A GroupDump holds the contents of a single group.
+type GroupDump struct {
+	Group Group
+	// Raw is the data exactly as stored in the image.
+	Raw []byte
+	// Data is the decompressed data. For Wii partition data it starts with
+	// the list of hash exceptions, and it is still packed if the group
+	// uses RVZ packing.
+	Data []byte
+	// Exceptions is the parsed list of hash exceptions for Wii partition
+	// data.
+	Exceptions []HashException
+}
+
+// parseExceptions parses the list of hash exceptions at the start of the
+// data of a Wii partition group.
+func parseExceptions(b []byte) ([]HashException, error) {
+	br := bytes.NewReader(b)
+
+	var n uint16
+	if err := binary.Read(br, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+
+	exceptions := make([]HashException, n)
+	if err := binary.Read(br, binary.BigEndian, exceptions); err != nil {
+		return nil, err
+	}
+
+	return exceptions, nil
+}
+
+// DumpGroup returns the contents of group g, both as stored in the image and
+// decompressed. If it can't be decompressed, the GroupDump returned with the
+// error holds the raw data and as much as could be decompressed.
+func (r *Reader) DumpGroup(g int) (*GroupDump, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+
+	if g < 0 || g >= len(r.group) {
+		return nil, fmt.Errorf("%w: %d", ErrGroupIndex, g)
+	}
+
+	groups, err := r.groups()
+	if err != nil {
+		return nil, err
+	}
+
+	gd := &GroupDump{Group: r.groupEntry(g)}
+
+	for _, x := range groups {
+		if x.Index == g {
+			gd.Group = x
+
+			break
+		}
+	}
+
+	group := &r.group[g]
+	partition := gd.Group.Region.Kind == PartitionData
+
+	gd.Raw = make([]byte, group.size())
+	if _, err = r.ra.ReadAt(gd.Raw, group.offset()); err != nil {
+		return gd, err
+	}
+
+	switch {
+	case group.compressed():
+		var rc io.ReadCloser
+
+		if rc, err = r.decompressor(bytes.NewReader(gd.Raw)); err != nil {
+			return gd, r.decodeError(err, gd.Group.DiscOffset, g, gd.Group.Region.Partition)
+		}
+
+		gd.Data, err = io.ReadAll(rc)
+		if cerr := rc.Close(); err == nil {
+			err = cerr
+		}
+
+		if err != nil {
+			return gd, r.decodeError(err, gd.Group.DiscOffset, g, gd.Group.Region.Partition)
+		}
+	case group.size() == 0:
+		size := r.disc.chunkSize(partition)
+		if partition {
+			size += int64(binary.Size(uint16(0)))
+		}
+
+		gd.Data = make([]byte, size)
+	default:
+		gd.Data = gd.Raw
+	}
+
+	if partition {
+		if gd.Exceptions, err = parseExceptions(gd.Data); err != nil {
+			return gd, r.decodeError(err, gd.Group.DiscOffset, g, gd.Group.Region.Partition)
+		}
+	}
+
+	return gd, nil
+}
@@ -0,0 +1,96 @@
+package rvz_test
+
+import (
+	"bytes"
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+//nolint:cyclop,funlen
+func TestGroups(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+
+	exception := make([]byte, 2+sha1.Size)
+	binary.BigEndian.PutUint16(exception, 0x14)
+
+	for i := 2; i < len(exception); i++ {
+		exception[i] = 0xaa
+	}
+
+	for _, method := range []uint32{methodNone, methodZstd} {
+		method := method
+
+		t.Run(fmt.Sprintf("Method%d", method), func(t *testing.T) {
+			t.Parallel()
+
+			r, err := rvz.NewReader(bytes.NewReader((&testImage{
+				method:     method,
+				iso:        iso,
+				partitions: []testPartition{tp},
+				exception:  exception,
+			}).build(t)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				groups []rvz.Group
+				offset int64 = -1
+			)
+
+			for it := r.Groups(); it.Next(); {
+				g := it.Group()
+				assert.Greater(t, g.DiscOffset, offset)
+				assert.Equal(t, len(groups), g.Index)
+
+				offset = g.DiscOffset
+				groups = append(groups, g)
+			}
+
+			if !assert.NotEmpty(t, groups) {
+				return
+			}
+
+			var partition bool
+
+			for _, g := range groups {
+				if g.Size == 0 {
+					continue
+				}
+
+				gd, err := r.DumpGroup(g.Index)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, g, gd.Group)
+				assert.Len(t, gd.Raw, int(g.Size))
+				assert.Equal(t, method != methodNone, g.Compressed)
+
+				if g.Region.Kind == rvz.PartitionData {
+					partition = true
+
+					if assert.Len(t, gd.Exceptions, 1) {
+						assert.Equal(t, uint16(0x14), gd.Exceptions[0].Offset)
+						assert.Equal(t, byte(0xaa), gd.Exceptions[0].Hash[0])
+					}
+				} else {
+					assert.Empty(t, gd.Exceptions)
+					assert.True(t, bytes.Equal(iso[g.DiscOffset:g.DiscOffset+int64(len(gd.Data))], gd.Data))
+				}
+			}
+
+			assert.True(t, partition)
+
+			_, err = r.DumpGroup(len(groups))
+			assert.ErrorIs(t, err, rvz.ErrGroupIndex)
+		})
+	}
+}
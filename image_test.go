@@ -0,0 +1,750 @@
+package rvz_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/bodgit/rvz/internal/padding"
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+const (
+	sectorSize     = 0x8000
+	hashSize       = 0x400
+	sectorDataSize = sectorSize - hashSize
+	clusterSectors = 64
+
+	methodNone  = 0
+	methodPurge = 1
+	methodBzip2 = 2
+	methodLZMA  = 3
+	methodLZMA2 = 4
+	methodZstd  = 5
+
+	testDictCap = 1 << 20
+)
+
+// testLZMAProps are the properties passed to the LZMA and LZMA2
+// decompressors; lc=3, lp=0, pb=2 and the dictionary size.
+//
+//nolint:gochecknoglobals
+var testLZMAProps = map[uint32][]byte{
+	methodLZMA:  {0x5d, 0x00, 0x00, 0x10, 0x00},
+	methodLZMA2: {16},
+}
+
+//nolint:maligned
+type testHeader struct {
+	Magic             uint32
+	Version           uint32
+	VersionCompatible uint32
+	DiscSize          uint32
+	DiscHash          [sha1.Size]byte
+	IsoFileSize       uint64
+	RvzFileSize       uint64
+	FileHeadHash      [sha1.Size]byte
+}
+
+type testDisc struct {
+	DiscType     uint32
+	Compression  uint32
+	ComprLevel   int32
+	ChunkSize    uint32
+	Header       [0x80]byte
+	NumPart      uint32
+	PartSize     uint32
+	PartOff      uint64
+	PartHash     [sha1.Size]byte
+	NumRawData   uint32
+	RawDataOff   uint64
+	RawDataSize  uint32
+	NumGroup     uint32
+	GroupOff     uint64
+	GroupSize    uint32
+	ComprDataLen byte
+	ComprData    [7]byte
+}
+
+type testRaw struct {
+	RawDataOff  uint64
+	RawDataSize uint64
+	GroupIndex  uint32
+	NumGroup    uint32
+}
+
+type testGroup struct {
+	Offset     uint32
+	Size       uint32
+	PackedSize uint32
+}
+
+type testPartData struct {
+	FirstSector uint32
+	NumSector   uint32
+	GroupIndex  uint32
+	NumGroup    uint32
+}
+
+type testPart struct {
+	Key  [16]byte
+	Data [2]testPartData
+}
+
+// testPartition is a Wii partition within a testImage.
+type testPartition struct {
+	key         [16]byte
+	firstSector uint32
+	data        []byte // decrypted data, without hashes
+}
+
+// testImage describes a GameCube or Wii disc image to be written as RVZ.
+type testImage struct {
+	method     uint32
+	chunkSize  uint32
+	iso        []byte
+	partitions []testPartition
+
+	// mangle, if set, may modify the group table before it's written
+	mangle func([]testGroup)
+
+	// seeds, if set, are the padding seeds of any sectors of raw data
+	// that are junk, which are packed rather than stored
+	seeds map[int64][]byte
+
+	// exception, if set, is added to the list of hash exceptions of every
+	// partition group
+	exception []byte
+
+	buf    bytes.Buffer
+	raw    []testRaw
+	parts  []testPart
+	groups []testGroup
+}
+
+func (ti *testImage) compress(tb testing.TB, b []byte) []byte {
+	tb.Helper()
+
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+
+	switch ti.method {
+	case methodBzip2:
+		// The standard library can only decompress bzip2
+		w, err = bzip2.NewWriter(&buf, nil)
+	case methodLZMA:
+		w, err = lzma.WriterConfig{
+			Properties: &lzma.Properties{LC: 3, LP: 0, PB: 2},
+			DictCap:    testDictCap,
+			EOSMarker:  true,
+		}.NewWriter(&buf)
+	case methodLZMA2:
+		w, err = lzma.Writer2Config{DictCap: testDictCap}.NewWriter2(&buf)
+	case methodZstd:
+		e, err := zstd.NewWriter(nil)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		defer e.Close()
+
+		return e.EncodeAll(b, nil)
+	default:
+		return b
+	}
+
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if _, err = w.Write(b); err != nil {
+		tb.Fatal(err)
+	}
+
+	if err = w.Close(); err != nil {
+		tb.Fatal(err)
+	}
+
+	if ti.method == methodLZMA {
+		// Remove the header as the properties are in the disc struct
+		return buf.Bytes()[lzma.HeaderLen:]
+	}
+
+	return buf.Bytes()
+}
+
+func (ti *testImage) align() {
+	for ti.buf.Len()%4 != 0 {
+		_ = ti.buf.WriteByte(0)
+	}
+}
+
+// pack returns the RVZ packed encoding of b, which starts at offset in the
+// disc image, replacing any junk sectors with their padding seeds.
+func (ti *testImage) pack(b []byte, offset int64) []byte {
+	var buf bytes.Buffer
+
+	for len(b) > 0 {
+		n := sectorSize - int(offset%sectorSize)
+		if n > len(b) {
+			n = len(b)
+		}
+
+		if seed, ok := ti.seeds[offset-offset%sectorSize]; ok {
+			_ = binary.Write(&buf, binary.BigEndian, uint32(n)|1<<31)
+			_, _ = buf.Write(seed)
+		} else {
+			_ = binary.Write(&buf, binary.BigEndian, uint32(n))
+			_, _ = buf.Write(b[:n])
+		}
+
+		b = b[n:]
+		offset += int64(n)
+	}
+
+	return buf.Bytes()
+}
+
+func (ti *testImage) writeGroup(tb testing.TB, b []byte, offset int64) {
+	tb.Helper()
+
+	if bytes.Count(b, []byte{0}) == len(b) {
+		ti.groups = append(ti.groups, testGroup{})
+
+		return
+	}
+
+	ti.align()
+
+	g := testGroup{Offset: uint32(ti.buf.Len() >> 2)}
+
+	if ti.seeds != nil {
+		b = ti.pack(b, offset)
+		g.PackedSize = uint32(len(b))
+	}
+
+	if c := ti.compress(tb, b); ti.method != methodNone && len(c) < len(b) {
+		g.Size = uint32(len(c)) | 1<<31
+		b = c
+	} else {
+		g.Size = uint32(len(b))
+	}
+
+	_, _ = ti.buf.Write(b)
+
+	ti.groups = append(ti.groups, g)
+}
+
+func (ti *testImage) writeRaw(tb testing.TB, offset, size uint64) {
+	tb.Helper()
+
+	start := offset - offset%sectorSize
+	r := testRaw{
+		RawDataOff:  offset,
+		RawDataSize: size,
+		GroupIndex:  uint32(len(ti.groups)),
+	}
+
+	for o := start; o < offset+size; o += uint64(ti.chunkSize) {
+		end := o + uint64(ti.chunkSize)
+		if end > offset+size {
+			end = offset + size
+		}
+
+		ti.writeGroup(tb, ti.iso[o:end], int64(o))
+		r.NumGroup++
+	}
+
+	ti.raw = append(ti.raw, r)
+}
+
+func (ti *testImage) writePartitionGroup(tb testing.TB, b []byte) {
+	tb.Helper()
+
+	if bytes.Count(b, []byte{0}) == len(b) {
+		ti.groups = append(ti.groups, testGroup{})
+
+		return
+	}
+
+	ti.align()
+
+	g := testGroup{Offset: uint32(ti.buf.Len() >> 2)}
+
+	// The list of exceptions, padded to the next 4 byte boundary if stored
+	list := []byte{0, 0}
+	if ti.exception != nil {
+		list = append([]byte{0, 1}, ti.exception...)
+	}
+
+	data := append(append([]byte{}, list...), b...)
+	plain := append(append(list, make([]byte, (4-len(list)%4)%4)...), b...)
+
+	if c := ti.compress(tb, data); ti.method != methodNone && len(c) < len(plain) {
+		g.Size = uint32(len(c)) | 1<<31
+		plain = c
+	} else {
+		g.Size = uint32(len(plain))
+	}
+
+	_, _ = ti.buf.Write(plain)
+
+	ti.groups = append(ti.groups, g)
+}
+
+func (ti *testImage) writePartition(tb testing.TB, tp testPartition) {
+	tb.Helper()
+
+	var (
+		spc = int(ti.chunkSize / sectorSize)
+		n   = len(tp.data) / sectorDataSize
+		p   = testPart{Key: tp.key}
+	)
+
+	p.Data[0] = testPartData{
+		FirstSector: tp.firstSector,
+		NumSector:   uint32(n),
+		GroupIndex:  uint32(len(ti.groups)),
+	}
+
+	for i := 0; i < n; i += spc {
+		end := i + spc
+		if end > n {
+			end = n
+		}
+
+		ti.writePartitionGroup(tb, tp.data[i*sectorDataSize:end*sectorDataSize])
+		p.Data[0].NumGroup++
+	}
+
+	p.Data[1].GroupIndex = uint32(len(ti.groups))
+
+	ti.parts = append(ti.parts, p)
+}
+
+func (ti *testImage) table(tb testing.TB, data interface{}) []byte {
+	tb.Helper()
+
+	b := new(bytes.Buffer)
+	if err := binary.Write(b, binary.BigEndian, data); err != nil {
+		tb.Fatal(err)
+	}
+
+	return ti.compress(tb, b.Bytes())
+}
+
+// build returns the RVZ encoding of the image.
+func (ti *testImage) build(tb testing.TB) []byte {
+	tb.Helper()
+
+	if ti.chunkSize == 0 {
+		ti.chunkSize = sectorSize << 2
+	}
+
+	var (
+		h testHeader
+		d testDisc
+	)
+
+	start := binary.Size(h) + binary.Size(d)
+	_, _ = ti.buf.Write(make([]byte, start))
+
+	offset := uint64(0x80)
+
+	for _, tp := range ti.partitions {
+		start := uint64(tp.firstSector) * sectorSize
+
+		ti.writeRaw(tb, offset, start-offset)
+		ti.writePartition(tb, tp)
+
+		offset = start + uint64(len(tp.data)/sectorDataSize)*sectorSize
+	}
+
+	ti.writeRaw(tb, offset, uint64(len(ti.iso))-offset)
+
+	ti.align()
+
+	d.DiscType = 1
+	d.PartHash = sha1.Sum(nil) //nolint:gosec
+
+	if len(ti.parts) > 0 {
+		partTable := new(bytes.Buffer)
+		_ = binary.Write(partTable, binary.BigEndian, ti.parts)
+
+		d.DiscType = 2
+		d.NumPart = uint32(len(ti.parts))
+		d.PartSize = uint32(binary.Size(ti.parts[0]))
+		d.PartOff = uint64(ti.buf.Len())
+		d.PartHash = sha1.Sum(partTable.Bytes()) //nolint:gosec
+		_, _ = ti.buf.Write(partTable.Bytes())
+	}
+
+	rawTable := ti.table(tb, ti.raw)
+	d.RawDataOff = uint64(ti.buf.Len())
+	d.RawDataSize = uint32(len(rawTable))
+	_, _ = ti.buf.Write(rawTable)
+
+	if ti.mangle != nil {
+		ti.mangle(ti.groups)
+	}
+
+	groupTable := ti.table(tb, ti.groups)
+	d.GroupOff = uint64(ti.buf.Len())
+	d.GroupSize = uint32(len(groupTable))
+	_, _ = ti.buf.Write(groupTable)
+
+	d.Compression = ti.method
+	d.ComprDataLen = byte(copy(d.ComprData[:], testLZMAProps[ti.method]))
+	d.ChunkSize = ti.chunkSize
+	copy(d.Header[:], ti.iso)
+	d.NumRawData = uint32(len(ti.raw))
+	d.NumGroup = uint32(len(ti.groups))
+
+	b := ti.buf.Bytes()
+
+	db := new(bytes.Buffer)
+	_ = binary.Write(db, binary.BigEndian, &d)
+
+	h.Magic = 0x52565a01
+	h.Version = 0x01000000
+	h.VersionCompatible = 0x00030000
+	h.DiscSize = uint32(db.Len())
+	h.DiscHash = sha1.Sum(db.Bytes()) //nolint:gosec
+	h.IsoFileSize = uint64(len(ti.iso))
+	h.RvzFileSize = uint64(len(b))
+
+	hb := new(bytes.Buffer)
+	_ = binary.Write(hb, binary.BigEndian, &h)
+	h.FileHeadHash = sha1.Sum(hb.Bytes()[:hb.Len()-sha1.Size]) //nolint:gosec
+
+	hb.Reset()
+	_ = binary.Write(hb, binary.BigEndian, &h)
+
+	copy(b, hb.Bytes())
+	copy(b[hb.Len():], db.Bytes())
+
+	return b
+}
+
+// testJunk replaces the given sectors of iso with junk data, returning the
+// padding seed used for each of them.
+func testJunk(tb testing.TB, iso []byte, sectors ...int) map[int64][]byte {
+	tb.Helper()
+
+	seeds := make(map[int64][]byte, len(sectors))
+
+	for _, s := range sectors {
+		offset := int64(s) * sectorSize
+
+		seed := make([]byte, padding.SeedSize)
+		for i := range seed {
+			seed[i] = byte(s*7 + i)
+		}
+
+		rc, err := padding.NewReadCloser(bytes.NewReader(seed), offset)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		if _, err = io.ReadFull(rc, iso[offset:offset+sectorSize]); err != nil {
+			tb.Fatal(err)
+		}
+
+		_ = rc.Close()
+		seeds[offset] = seed
+	}
+
+	return seeds
+}
+
+// testFileSystem writes a boot header, apploader, main executable and file
+// system table into b, the start of a disc image or of the decrypted data of
+// a Wii partition, describing each file at the given offsets and sizes.
+// Offsets on a Wii disc are stored shifted right by two.
+func testFileSystem(b []byte, shift uint, files ...[2]uint32) {
+	const (
+		dolOffset = 0x18000
+		fstOffset = 0x28000
+	)
+
+	binary.BigEndian.PutUint32(b[0x420:], dolOffset>>shift)
+	binary.BigEndian.PutUint32(b[0x424:], fstOffset>>shift)
+	binary.BigEndian.PutUint32(b[0x428:], uint32((len(files)+1)*0xc)>>shift)
+
+	// An apploader of 0x1000 bytes with a trailer of 0x20 bytes
+	binary.BigEndian.PutUint32(b[0x2440+0x14:], 0x1000)
+	binary.BigEndian.PutUint32(b[0x2440+0x18:], 0x20)
+
+	// A single text section
+	dol := b[dolOffset:]
+	for i := 0; i < 0x100; i++ {
+		dol[i] = 0
+	}
+
+	binary.BigEndian.PutUint32(dol, 0x100)
+	binary.BigEndian.PutUint32(dol[0x90:], 0x1000)
+
+	fst := b[fstOffset:]
+	binary.BigEndian.PutUint32(fst, 0x01000000)
+	binary.BigEndian.PutUint32(fst[4:], 0)
+	binary.BigEndian.PutUint32(fst[8:], uint32(len(files)+1))
+
+	for i, f := range files {
+		e := fst[(i+1)*0xc:]
+		binary.BigEndian.PutUint32(e, 0)
+		binary.BigEndian.PutUint32(e[4:], f[0]>>shift)
+		binary.BigEndian.PutUint32(e[8:], f[1])
+	}
+}
+
+// testISO returns a GameCube-sized image of n sectors with a mix of
+// compressible, incompressible and all-zero areas.
+func testISO(n int) []byte {
+	b := make([]byte, n*sectorSize)
+	copy(b, "GALE01")
+
+	var x uint32 = 1
+
+	for i := 0x440; i < len(b); i++ {
+		switch (i / sectorSize) % 4 {
+		case 0:
+			b[i] = byte(i)
+		case 1:
+			x ^= x << 13
+			x ^= x >> 17
+			x ^= x << 5
+			b[i] = byte(x)
+		case 2:
+		case 3:
+			b[i] = byte(i / 0x100)
+		}
+	}
+
+	return b
+}
+
+const (
+	headerSize = 0x48
+
+	discNumPart      = headerSize + 0x90
+	discPartSize     = headerSize + 0x94
+	discPartOff      = headerSize + 0x98
+	discPartHash     = headerSize + 0xa0
+	discNumGroup     = headerSize + 0xc4
+	discGroupOff     = headerSize + 0xc8
+	discComprDataLen = headerSize + 0xd4
+	discSize         = 0xdc
+)
+
+// rehash recalculates the header, disc and partition hashes so that images
+// modified after being built still get past the integrity checks.
+func rehash(b []byte) {
+	if len(b) < headerSize+discSize {
+		return
+	}
+
+	partOff := binary.BigEndian.Uint64(b[discPartOff:])
+	partSize := uint64(binary.BigEndian.Uint32(b[discNumPart:])) * uint64(binary.BigEndian.Uint32(b[discPartSize:]))
+
+	if partOff <= uint64(len(b)) && partSize <= uint64(len(b))-partOff {
+		h := sha1.Sum(b[partOff : partOff+partSize]) //nolint:gosec
+		copy(b[discPartHash:], h[:])
+	}
+
+	h := sha1.Sum(b[headerSize : headerSize+discSize]) //nolint:gosec
+	copy(b[0x10:], h[:])
+
+	h = sha1.Sum(b[:headerSize-sha1.Size]) //nolint:gosec
+	copy(b[headerSize-sha1.Size:], h[:])
+}
+
+// encryptPartition returns the encrypted form of the decrypted partition
+// data, padded to a whole number of clusters, along with its H3 table.
+func encryptPartition(tb testing.TB, key [16]byte, data []byte) ([]byte, []byte) {
+	tb.Helper()
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	n := (len(data)/sectorDataSize + clusterSectors - 1) / clusterSectors
+
+	padded := make([]byte, n*clusterSectors*sectorDataSize)
+	copy(padded, data)
+
+	enc := make([]byte, n*clusterSectors*sectorSize)
+	h3 := make([]byte, 0x18000)
+
+	for c := 0; c < n; c++ {
+		var (
+			h0 [clusterSectors][0x26c]byte
+			h1 [8][0xa0]byte
+			h2 [0xa0]byte
+		)
+
+		for s := range h0 {
+			sector := padded[(c*clusterSectors+s)*sectorDataSize:]
+			for k := 0; k < 31; k++ {
+				sum := sha1.Sum(sector[k*0x400 : (k+1)*0x400]) //nolint:gosec
+				copy(h0[s][k*sha1.Size:], sum[:])
+			}
+		}
+
+		for i := range h1 {
+			for j := 0; j < 8; j++ {
+				sum := sha1.Sum(h0[i*8+j][:]) //nolint:gosec
+				copy(h1[i][j*sha1.Size:], sum[:])
+			}
+
+			sum := sha1.Sum(h1[i][:]) //nolint:gosec
+			copy(h2[i*sha1.Size:], sum[:])
+		}
+
+		sum := sha1.Sum(h2[:]) //nolint:gosec
+		copy(h3[c*sha1.Size:], sum[:])
+
+		for s := range h0 {
+			hb := make([]byte, hashSize)
+			copy(hb, h0[s][:])
+			copy(hb[0x280:], h1[s/8][:])
+			copy(hb[0x340:], h2[:])
+
+			out := enc[(c*clusterSectors+s)*sectorSize:]
+			cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out[:hashSize], hb)
+
+			in := padded[(c*clusterSectors+s)*sectorDataSize:]
+			cipher.NewCBCEncrypter(block, out[0x3d0:0x3e0]).CryptBlocks(out[hashSize:sectorSize], in[:sectorDataSize])
+		}
+	}
+
+	return enc, h3
+}
+
+const (
+	testPartOffset = 0x50000
+	testTMDOffset  = 0x2c0
+	testTMDSize    = 0x1e4 + 0x24
+	testH3Offset   = 0x8000
+	testDataOffset = 0x20000
+
+	testSigRSA2048 = 0x10001
+)
+
+// testWiiISO returns a Wii disc image with a single partition holding n
+// sectors of data. The second cluster of the partition is all zeroes.
+// testCommonKey stands in for the retail common key.
+var testCommonKey = [aes.BlockSize]byte{0: 0xc0, 1: 0xff, 2: 0xee} //nolint:gochecknoglobals
+
+func encryptTitleKey(tb testing.TB, dst []byte, key [aes.BlockSize]byte, titleID []byte) {
+	tb.Helper()
+
+	block, err := aes.NewCipher(testCommonKey[:])
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, titleID)
+
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(dst, key[:])
+}
+
+// fakesign alters the counter at offset in body until its hash starts with
+// a zero byte, as the trucha bug requires for a blank signature.
+func fakesign(body []byte, offset int) {
+	for i := uint32(0); ; i++ {
+		binary.BigEndian.PutUint32(body[offset:], i)
+
+		if sum := sha1.Sum(body); sum[0] == 0 { //nolint:gosec
+			return
+		}
+	}
+}
+
+func testWiiISO(tb testing.TB, n int) ([]byte, testPartition) {
+	tb.Helper()
+
+	data := make([]byte, n*sectorDataSize)
+
+	var x uint32 = 1
+
+	for i := range data {
+		switch s := i / sectorDataSize; {
+		case s/clusterSectors == 1:
+		case s%2 == 0:
+			data[i] = byte(i / 0x100)
+		default:
+			x ^= x << 13
+			x ^= x >> 17
+			x ^= x << 5
+			data[i] = byte(x)
+		}
+	}
+
+	return testWiiImage(tb, data)
+}
+
+// testWiiImage returns a Wii disc image with a single partition holding the
+// decrypted data, which must be a whole number of sectors.
+func testWiiImage(tb testing.TB, data []byte) ([]byte, testPartition) {
+	tb.Helper()
+
+	n := len(data) / sectorDataSize
+	tp := testPartition{
+		key:         [16]byte{0: 0xde, 1: 0xad, 2: 0xbe, 3: 0xef},
+		firstSector: (testPartOffset + testDataOffset) / sectorSize,
+		data:        data,
+	}
+
+	iso := make([]byte, testPartOffset+testDataOffset+(n+4)*sectorSize)
+	copy(iso, "RMCE01")
+	binary.BigEndian.PutUint32(iso[0x18:], 0x5d1c9ea3)
+
+	// A single partition table with one entry
+	binary.BigEndian.PutUint32(iso[0x40000:], 1)
+	binary.BigEndian.PutUint32(iso[0x40004:], 0x40020>>2)
+	binary.BigEndian.PutUint32(iso[0x40020:], testPartOffset>>2)
+
+	ph := iso[testPartOffset:]
+	binary.BigEndian.PutUint32(ph, testSigRSA2048)
+	copy(ph[0x140:], "Root-CA00000001-XS00000003")
+	copy(ph[0x1dc:], "\x00\x01\x00\x00RMCE")
+	encryptTitleKey(tb, ph[0x1bf:0x1bf+aes.BlockSize], tp.key, ph[0x1dc:0x1dc+8])
+	fakesign(ph[0x140:0x2a4], 0x1f2-0x140)
+	binary.BigEndian.PutUint32(ph[0x2a4:], testTMDSize)
+	binary.BigEndian.PutUint32(ph[0x2a8:], testTMDOffset>>2)
+	binary.BigEndian.PutUint32(ph[0x2b4:], testH3Offset>>2)
+	binary.BigEndian.PutUint32(ph[0x2b8:], testDataOffset>>2)
+	binary.BigEndian.PutUint32(ph[0x2bc:], uint32(n*sectorSize>>2))
+
+	enc, h3 := encryptPartition(tb, tp.key, tp.data)
+	copy(ph[testDataOffset:], enc[:n*sectorSize])
+	copy(ph[testH3Offset:], h3)
+
+	tmd := ph[testTMDOffset:]
+	binary.BigEndian.PutUint32(tmd, testSigRSA2048)
+	copy(tmd[0x140:], "Root-CA00000001-CP00000004")
+	binary.BigEndian.PutUint64(tmd[0x184:], 0x0000000100000024) // IOS36
+	copy(tmd[0x18c:], "\x00\x01\x00\x00RMCE")
+	binary.BigEndian.PutUint16(tmd[0x1de:], 1)
+	binary.BigEndian.PutUint64(tmd[0x1e4+8:], uint64(n*sectorSize))
+
+	sum := sha1.Sum(h3) //nolint:gosec
+	copy(tmd[0x1e4+0x10:], sum[:])
+	fakesign(tmd[0x140:testTMDSize], 0x1ae-0x140)
+
+	for i := testPartOffset + testDataOffset + n*sectorSize; i < len(iso); i++ {
+		iso[i] = byte(i)
+	}
+
+	return iso, tp
+}
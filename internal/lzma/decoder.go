@@ -0,0 +1,612 @@
+package lzma
+
+import (
+	"errors"
+	"io"
+)
+
+// Errors returned when decoding.
+var (
+	ErrProperties = errors.New("lzma: bad properties")
+	ErrCorrupt    = errors.New("lzma: corrupt data")
+	ErrDistance   = errors.New("lzma: match distance out of range")
+)
+
+const (
+	numStates     = 12
+	numPosBitsMax = 4
+
+	numLenToPosStates = 4
+	numAlignBits      = 4
+	startPosModel     = 4
+	endPosModel       = 14
+	numFullDistances  = 1 << (endPosModel >> 1)
+	matchMinLen       = 2
+
+	probBits = 11
+	probInit = 1 << (probBits - 1)
+	moveBits = 5
+	topValue = 1 << 24
+
+	literalCoderSize = 0x300
+	endMarker        = 0xffffffff
+)
+
+type prob uint16
+
+func initProbs(p []prob) {
+	for i := range p {
+		p[i] = probInit
+	}
+}
+
+// rangeDecoder decodes bits from the range coded input.
+type rangeDecoder struct {
+	br    io.ByteReader
+	rng   uint32
+	code  uint32
+	err   error
+	count int64 // number of bytes consumed
+}
+
+func (rc *rangeDecoder) readByte() byte {
+	b, err := rc.br.ReadByte()
+	if err != nil {
+		if rc.err == nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+
+			rc.err = err
+		}
+
+		return 0
+	}
+
+	rc.count++
+
+	return b
+}
+
+func (rc *rangeDecoder) init(br io.ByteReader) error {
+	*rc = rangeDecoder{br: br, rng: 0xffffffff}
+
+	if rc.readByte() != 0 {
+		if rc.err != nil {
+			return rc.err
+		}
+
+		return ErrCorrupt
+	}
+
+	for i := 0; i < 4; i++ {
+		rc.code = rc.code<<8 | uint32(rc.readByte())
+	}
+
+	if rc.code == rc.rng {
+		return ErrCorrupt
+	}
+
+	return rc.err
+}
+
+func (rc *rangeDecoder) finished() bool {
+	return rc.code == 0
+}
+
+func (rc *rangeDecoder) normalize() {
+	if rc.rng < topValue {
+		rc.shift()
+	}
+}
+
+func (rc *rangeDecoder) shift() {
+	rc.rng <<= 8
+	rc.code = rc.code<<8 | uint32(rc.readByte())
+}
+
+// decodeBit is kept small enough to be inlined, as it is called for every
+// bit decoded.
+func (rc *rangeDecoder) decodeBit(p *prob) uint32 {
+	bound := (rc.rng >> probBits) * uint32(*p)
+	if rc.code < bound {
+		*p += ((1 << probBits) - *p) >> moveBits
+		rc.rng = bound
+		rc.normalize()
+
+		return 0
+	}
+
+	*p -= *p >> moveBits
+	rc.code -= bound
+	rc.rng -= bound
+	rc.normalize()
+
+	return 1
+}
+
+func (rc *rangeDecoder) directBits(n int) uint32 {
+	var res uint32
+
+	for ; n > 0; n-- {
+		rc.rng >>= 1
+		rc.code -= rc.rng
+		t := 0 - (rc.code >> 31)
+		rc.code += rc.rng & t
+
+		if rc.code == rc.rng {
+			rc.err = ErrCorrupt
+		}
+
+		res = res<<1 + t + 1
+
+		rc.normalize()
+	}
+
+	return res
+}
+
+func (rc *rangeDecoder) bitTree(probs []prob, numBits int) uint32 {
+	m := uint32(1)
+	for i := 0; i < numBits; i++ {
+		m = m<<1 + rc.decodeBit(&probs[m])
+	}
+
+	return m - 1<<numBits
+}
+
+func (rc *rangeDecoder) reverseBitTree(probs []prob, numBits int) uint32 {
+	var sym uint32
+
+	m := uint32(1)
+	for i := 0; i < numBits; i++ {
+		bit := rc.decodeBit(&probs[m])
+		m = m<<1 + bit
+		sym |= bit << i
+	}
+
+	return sym
+}
+
+type lenDecoder struct {
+	choice  prob
+	choice2 prob
+	low     [1 << numPosBitsMax][1 << 3]prob
+	mid     [1 << numPosBitsMax][1 << 3]prob
+	high    [1 << 8]prob
+}
+
+func (ld *lenDecoder) init() {
+	ld.choice, ld.choice2 = probInit, probInit
+
+	for i := range ld.low {
+		initProbs(ld.low[i][:])
+		initProbs(ld.mid[i][:])
+	}
+
+	initProbs(ld.high[:])
+}
+
+func (ld *lenDecoder) decode(rc *rangeDecoder, posState uint32) uint32 {
+	if rc.decodeBit(&ld.choice) == 0 {
+		return rc.bitTree(ld.low[posState][:], 3)
+	}
+
+	if rc.decodeBit(&ld.choice2) == 0 {
+		return 8 + rc.bitTree(ld.mid[posState][:], 3)
+	}
+
+	return 16 + rc.bitTree(ld.high[:], 8)
+}
+
+// window is the dictionary of previously decoded data. It grows as data is
+// decoded until it reaches the dictionary size, after which it wraps around,
+// so a small stream never needs the whole dictionary to be allocated.
+type window struct {
+	buf  []byte
+	size int
+	pos  int
+	full bool
+}
+
+func (w *window) reset(size int) {
+	w.buf = w.buf[:0]
+	w.size = size
+	w.pos = 0
+	w.full = false
+}
+
+// has reports whether the byte at distance dist, counting from one, has been
+// decoded and is still in the window.
+func (w *window) has(dist uint32) bool {
+	if w.full {
+		return int64(dist) <= int64(w.size)
+	}
+
+	return int64(dist) <= int64(w.pos)
+}
+
+func (w *window) empty() bool {
+	return !w.full && w.pos == 0
+}
+
+func (w *window) put(b byte) {
+	if !w.full {
+		w.buf = append(w.buf, b)
+		w.pos++
+
+		if w.pos == w.size {
+			w.pos, w.full = 0, true
+		}
+
+		return
+	}
+
+	w.buf[w.pos] = b
+	w.pos++
+
+	if w.pos == w.size {
+		w.pos = 0
+	}
+}
+
+func (w *window) get(dist uint32) byte {
+	i := w.pos - int(dist)
+	if i < 0 {
+		i += len(w.buf)
+	}
+
+	return w.buf[i]
+}
+
+// Properties are the literal context bits, literal position bits and
+// position bits used to encode a stream.
+type Properties struct {
+	LC, LP, PB int
+}
+
+// ParseProperties parses the properties from the single byte encoding.
+func ParseProperties(b byte) (Properties, error) {
+	if b >= 9*5*5 {
+		return Properties{}, ErrProperties
+	}
+
+	d := int(b)
+
+	return Properties{LC: d % 9, LP: d / 9 % 5, PB: d / 45}, nil
+}
+
+// Decoder decodes LZMA data. It can be reset and reused for another stream,
+// which avoids reallocating the dictionary and probability tables.
+type Decoder struct {
+	rc     rangeDecoder
+	window window
+	props  Properties
+
+	literal    []prob
+	posSlot    [numLenToPosStates][1 << 6]prob
+	posDecoder [1 + numFullDistances - endPosModel]prob
+	align      [1 << numAlignBits]prob
+	lenDec     lenDecoder
+	repLenDec  lenDecoder
+
+	isMatch    [numStates << numPosBitsMax]prob
+	isRep      [numStates]prob
+	isRepG0    [numStates]prob
+	isRepG1    [numStates]prob
+	isRepG2    [numStates]prob
+	isRep0Long [numStates << numPosBitsMax]prob
+
+	state                  uint32
+	rep0, rep1, rep2, rep3 uint32
+	total                  uint64
+
+	// remaining bytes of the current match
+	matchLen int
+	eos      bool
+}
+
+// ResetDict empties the dictionary and sets its size.
+func (d *Decoder) ResetDict(size int) {
+	d.window.reset(size)
+	d.total = 0
+}
+
+// ResetState resets the probabilities and state using new properties.
+func (d *Decoder) ResetState(props Properties) {
+	d.props = props
+
+	n := literalCoderSize << (props.LC + props.LP)
+	if cap(d.literal) < n {
+		d.literal = make([]prob, n)
+	}
+
+	d.literal = d.literal[:n]
+	initProbs(d.literal)
+
+	for i := range d.posSlot {
+		initProbs(d.posSlot[i][:])
+	}
+
+	initProbs(d.posDecoder[:])
+	initProbs(d.align[:])
+	d.lenDec.init()
+	d.repLenDec.init()
+	initProbs(d.isMatch[:])
+	initProbs(d.isRep[:])
+	initProbs(d.isRepG0[:])
+	initProbs(d.isRepG1[:])
+	initProbs(d.isRepG2[:])
+	initProbs(d.isRep0Long[:])
+
+	d.state = 0
+	d.rep0, d.rep1, d.rep2, d.rep3 = 0, 0, 0, 0
+	d.matchLen = 0
+	d.eos = false
+}
+
+// Properties returns the properties currently in use.
+func (d *Decoder) Properties() Properties {
+	return d.props
+}
+
+// ResetRange starts decoding range coded data from br, keeping the
+// dictionary and state.
+func (d *Decoder) ResetRange(br io.ByteReader) error {
+	return d.rc.init(br)
+}
+
+// Reset prepares the decoder to decode a new stream from br.
+func (d *Decoder) Reset(br io.ByteReader, props Properties, dictSize int) error {
+	d.ResetDict(dictSize)
+	d.ResetState(props)
+
+	return d.ResetRange(br)
+}
+
+// Consumed returns how many bytes have been read since the range decoder was
+// last reset.
+func (d *Decoder) Consumed() int64 {
+	return d.rc.count
+}
+
+// Finished reports whether the end marker has been decoded.
+func (d *Decoder) Finished() bool {
+	return d.eos
+}
+
+// Write adds uncompressed data to the dictionary.
+func (d *Decoder) Write(p []byte) (int, error) {
+	for _, b := range p {
+		d.window.put(b)
+	}
+
+	d.total += uint64(len(p))
+
+	return len(p), nil
+}
+
+func (d *Decoder) putByte(p []byte, n int, b byte) {
+	p[n] = b
+	d.window.put(b)
+	d.total++
+}
+
+func (d *Decoder) decodeLiteral() byte {
+	var prev uint32
+	if !d.window.empty() {
+		prev = uint32(d.window.get(1))
+	}
+
+	litState := ((uint32(d.total) & (1<<d.props.LP - 1)) << d.props.LC) + prev>>(8-d.props.LC)
+	probs := d.literal[literalCoderSize*litState : literalCoderSize*(litState+1)]
+
+	symbol := uint32(1)
+
+	if d.state >= 7 {
+		matchByte := uint32(d.window.get(d.rep0 + 1))
+
+		for symbol < 0x100 {
+			matchBit := (matchByte >> 7) & 1
+			matchByte <<= 1
+			bit := d.rc.decodeBit(&probs[((1+matchBit)<<8)+symbol])
+			symbol = symbol<<1 | bit
+
+			if matchBit != bit {
+				break
+			}
+		}
+	}
+
+	// This is where most of the time goes so the bit decoding is inlined
+	// by hand, keeping the range coder in local variables
+	rng, code := d.rc.rng, d.rc.code
+
+	for symbol < 0x100 {
+		p := &probs[symbol]
+
+		bound := (rng >> probBits) * uint32(*p)
+		if code < bound {
+			*p += ((1 << probBits) - *p) >> moveBits
+			rng = bound
+			symbol <<= 1
+		} else {
+			*p -= *p >> moveBits
+			code -= bound
+			rng -= bound
+			symbol = symbol<<1 | 1
+		}
+
+		if rng < topValue {
+			rng <<= 8
+			code = code<<8 | uint32(d.rc.readByte())
+		}
+	}
+
+	d.rc.rng, d.rc.code = rng, code
+
+	switch {
+	case d.state < 4:
+		d.state = 0
+	case d.state < 10:
+		d.state -= 3
+	default:
+		d.state -= 6
+	}
+
+	return byte(symbol)
+}
+
+func (d *Decoder) decodeDistance(length uint32) uint32 {
+	lenState := length
+	if lenState > numLenToPosStates-1 {
+		lenState = numLenToPosStates - 1
+	}
+
+	posSlot := d.rc.bitTree(d.posSlot[lenState][:], 6)
+	if posSlot < startPosModel {
+		return posSlot
+	}
+
+	numDirectBits := int(posSlot>>1) - 1
+	dist := (2 | posSlot&1) << numDirectBits
+
+	if posSlot < endPosModel {
+		return dist + d.rc.reverseBitTree(d.posDecoder[dist-posSlot:], numDirectBits)
+	}
+
+	dist += d.rc.directBits(numDirectBits-numAlignBits) << numAlignBits
+
+	return dist + d.rc.reverseBitTree(d.align[:], numAlignBits)
+}
+
+// decodeMatch decodes the next match, setting up the distance and length,
+// or decodes a single byte if it's a short rep match.
+//
+//nolint:cyclop
+func (d *Decoder) decodeMatch(posState uint32) (b byte, short bool, err error) {
+	if d.rc.decodeBit(&d.isRep[d.state]) != 0 {
+		if d.window.empty() {
+			return 0, false, ErrCorrupt
+		}
+
+		if d.rc.decodeBit(&d.isRepG0[d.state]) == 0 {
+			if d.rc.decodeBit(&d.isRep0Long[d.state<<numPosBitsMax+posState]) == 0 {
+				if d.state < 7 {
+					d.state = 9
+				} else {
+					d.state = 11
+				}
+
+				if !d.window.has(d.rep0 + 1) {
+					return 0, false, ErrDistance
+				}
+
+				return d.window.get(d.rep0 + 1), true, nil
+			}
+		} else {
+			var dist uint32
+
+			if d.rc.decodeBit(&d.isRepG1[d.state]) == 0 {
+				dist = d.rep1
+			} else {
+				if d.rc.decodeBit(&d.isRepG2[d.state]) == 0 {
+					dist = d.rep2
+				} else {
+					dist = d.rep3
+					d.rep3 = d.rep2
+				}
+
+				d.rep2 = d.rep1
+			}
+
+			d.rep1 = d.rep0
+			d.rep0 = dist
+		}
+
+		if !d.window.has(d.rep0 + 1) {
+			return 0, false, ErrDistance
+		}
+
+		d.matchLen = int(d.repLenDec.decode(&d.rc, posState)) + matchMinLen
+
+		if d.state < 7 {
+			d.state = 8
+		} else {
+			d.state = 11
+		}
+
+		return 0, false, nil
+	}
+
+	d.rep3, d.rep2, d.rep1 = d.rep2, d.rep1, d.rep0
+	length := d.lenDec.decode(&d.rc, posState)
+
+	if d.state < 7 {
+		d.state = 7
+	} else {
+		d.state = 10
+	}
+
+	d.rep0 = d.decodeDistance(length)
+	if d.rep0 == endMarker {
+		d.eos = true
+
+		return 0, false, io.EOF
+	}
+
+	if !d.window.has(d.rep0 + 1) {
+		return 0, false, ErrDistance
+	}
+
+	d.matchLen = int(length) + matchMinLen
+
+	return 0, false, nil
+}
+
+// Read decodes up to len(p) bytes. It returns io.EOF once the end marker
+// has been decoded.
+func (d *Decoder) Read(p []byte) (n int, err error) {
+	if d.eos {
+		return 0, io.EOF
+	}
+
+	for n < len(p) {
+		// Continue any match that didn't fit last time
+		for ; d.matchLen > 0 && n < len(p); d.matchLen-- {
+			d.putByte(p, n, d.window.get(d.rep0+1))
+			n++
+		}
+
+		if n == len(p) {
+			break
+		}
+
+		posState := uint32(d.total) & (1<<d.props.PB - 1)
+
+		if d.rc.decodeBit(&d.isMatch[d.state<<numPosBitsMax+posState]) == 0 {
+			d.putByte(p, n, d.decodeLiteral())
+			n++
+		} else {
+			b, short, err := d.decodeMatch(posState)
+			if err != nil {
+				if errors.Is(err, io.EOF) && !d.rc.finished() {
+					err = ErrCorrupt
+				}
+
+				return n, err
+			}
+
+			if short {
+				d.putByte(p, n, b)
+				n++
+			}
+		}
+
+		if d.rc.err != nil {
+			return n, d.rc.err
+		}
+	}
+
+	return n, nil
+}
@@ -1,26 +1,58 @@
 package lzma
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/binary"
 	"io"
-
-	"github.com/ulikunitz/xz/lzma"
+	"sync"
 )
 
-const (
-	unknownSize uint64 = 1<<64 - 1
-)
+// minDictSize is the smallest dictionary used, whatever the header says.
+const minDictSize = 1 << 12
+
+//nolint:gochecknoglobals
+var readerPool sync.Pool
+
+type readCloser struct {
+	Decoder
+	br *bufio.Reader
+}
+
+func (rc *readCloser) Close() error {
+	rc.br.Reset(nil)
+	readerPool.Put(rc)
+
+	return nil
+}
 
-// NewReader returns a new LZMA io.ReadCloser.
+// NewReader returns a new LZMA io.ReadCloser. The properties are the single
+// byte encoding of lc, lp and pb followed by the little-endian dictionary
+// size. Closing the reader returns it to a pool to be reused.
 func NewReader(p []byte, reader io.Reader) (io.ReadCloser, error) {
-	b := bytes.NewBuffer(p)
-	_ = binary.Write(b, binary.LittleEndian, unknownSize)
+	if len(p) != 5 {
+		return nil, ErrProperties
+	}
 
-	r, err := lzma.NewReader(io.MultiReader(b, reader))
+	props, err := ParseProperties(p[0])
 	if err != nil {
 		return nil, err
 	}
 
-	return io.NopCloser(r), nil
+	dictSize := int64(binary.LittleEndian.Uint32(p[1:]))
+	if dictSize < minDictSize {
+		dictSize = minDictSize
+	}
+
+	rc, ok := readerPool.Get().(*readCloser)
+	if ok {
+		rc.br.Reset(reader)
+	} else {
+		rc = &readCloser{br: bufio.NewReader(reader)}
+	}
+
+	if err = rc.Reset(rc.br, props, int(dictSize)); err != nil {
+		return nil, err
+	}
+
+	return rc, nil
 }
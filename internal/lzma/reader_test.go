@@ -0,0 +1,241 @@
+package lzma_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/bodgit/rvz/internal/lzma"
+	"github.com/stretchr/testify/assert"
+	xlzma "github.com/ulikunitz/xz/lzma"
+)
+
+const (
+	testSize = 1 << 17
+
+	// maxSize is the most that is decoded from any stream
+	maxSize = 1 << 20
+)
+
+// testData returns n bytes made up of text, runs of zeroes, random bytes and
+// copies of earlier data, so that every kind of literal and match is used.
+func testData(seed int64, n int) []byte {
+	words := []string{"nintendo ", "gamecube ", "wii ", "disc ", "sector ", "cluster ", "junk ", "\n"}
+	r := rand.New(rand.NewSource(seed)) //nolint:gosec
+	b := make([]byte, 0, n)
+
+	for len(b) < n {
+		switch r.Intn(4) {
+		case 0:
+			for i := r.Intn(50); i >= 0; i-- {
+				b = append(b, words[r.Intn(len(words))]...)
+			}
+		case 1:
+			b = append(b, make([]byte, r.Intn(2000))...)
+		case 2:
+			for i := r.Intn(500); i >= 0; i-- {
+				b = append(b, byte(r.Intn(256)))
+			}
+		case 3:
+			if len(b) == 0 {
+				continue
+			}
+
+			start := r.Intn(len(b))
+			for i := r.Intn(300); i >= 0; i-- {
+				b = append(b, b[start+i%(len(b)-start)])
+			}
+		}
+	}
+
+	return b[:n]
+}
+
+// compress returns the header and the compressed data of b.
+func compress(tb testing.TB, b []byte, props xlzma.Properties, dictCap int, eos bool) ([]byte, []byte) {
+	tb.Helper()
+
+	buf := new(bytes.Buffer)
+
+	w, err := xlzma.WriterConfig{
+		Properties:   &props,
+		DictCap:      dictCap,
+		SizeInHeader: !eos,
+		Size:         int64(len(b)),
+		EOSMarker:    eos,
+	}.NewWriter(buf)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if _, err = w.Write(b); err != nil {
+		tb.Fatal(err)
+	}
+
+	if err = w.Close(); err != nil {
+		tb.Fatal(err)
+	}
+
+	return buf.Bytes()[:xlzma.HeaderLen], buf.Bytes()[xlzma.HeaderLen:]
+}
+
+// decompress decodes size bytes, or everything up to the end marker if size
+// is negative, but never more than maxSize bytes.
+func decompress(header, data []byte, size int64) ([]byte, error) {
+	rc, err := lzma.NewReader(header[:5], bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if size < 0 {
+		return io.ReadAll(io.LimitReader(rc, maxSize))
+	}
+
+	b := make([]byte, size)
+	n, err := io.ReadFull(rc, b)
+
+	return b[:n], err
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	b := testData(1, testSize)
+
+	for _, props := range []xlzma.Properties{
+		{LC: 3, LP: 0, PB: 2},
+		{LC: 0, LP: 0, PB: 0},
+		{LC: 8, LP: 0, PB: 0},
+		{LC: 0, LP: 4, PB: 4},
+		{LC: 4, LP: 0, PB: 4},
+		{LC: 1, LP: 3, PB: 1},
+	} {
+		for _, dictCap := range []int{1 << 12, 1 << 16, 1 << 20} {
+			for _, eos := range []bool{true, false} {
+				props, dictCap, eos := props, dictCap, eos
+				name := fmt.Sprintf("LC%dLP%dPB%d/Dict%d/EOS%v", props.LC, props.LP, props.PB, dictCap, eos)
+
+				t.Run(name, func(t *testing.T) {
+					t.Parallel()
+
+					header, data := compress(t, b, props, dictCap, eos)
+
+					r, err := xlzma.NewReader(io.MultiReader(bytes.NewReader(header), bytes.NewReader(data)))
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					want, err := io.ReadAll(r)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					size := int64(-1)
+					if !eos {
+						size = int64(len(b))
+					}
+
+					got, err := decompress(header, data, size)
+					assert.NoError(t, err)
+					assert.Equal(t, want, got)
+					assert.Equal(t, b, got)
+				})
+			}
+		}
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	t.Parallel()
+
+	b := testData(2, testSize)
+	header, data := compress(t, b, xlzma.Properties{LC: 3, LP: 0, PB: 2}, 1<<16, true)
+
+	_, err := decompress(header, data[:len(data)/2], -1)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Without the end marker there's nothing to say where the data stops
+	header, data = compress(t, b, xlzma.Properties{LC: 3, LP: 0, PB: 2}, 1<<16, false)
+
+	_, err = decompress(header, data[:len(data)/2], int64(len(b)))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+// TestReuse checks a reader returned to the pool, even part way through a
+// stream, decodes the next stream correctly whatever its properties and
+// dictionary size.
+//
+//nolint:paralleltest
+func TestReuse(t *testing.T) {
+	tables := []struct {
+		props   xlzma.Properties
+		dictCap int
+	}{
+		{xlzma.Properties{LC: 3, LP: 0, PB: 2}, 1 << 20},
+		{xlzma.Properties{LC: 0, LP: 4, PB: 4}, 1 << 12},
+		{xlzma.Properties{LC: 8, LP: 0, PB: 0}, 1 << 16},
+		{xlzma.Properties{LC: 3, LP: 0, PB: 2}, 1 << 12},
+	}
+
+	for i, table := range tables {
+		b := testData(int64(i), testSize)
+		header, data := compress(t, b, table.props, table.dictCap, true)
+
+		rc, err := lzma.NewReader(header[:5], bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Abandon the first reader part way through
+		_, err = io.CopyN(io.Discard, rc, int64(len(b)/3))
+		assert.NoError(t, err)
+		assert.NoError(t, rc.Close())
+
+		got, err := decompress(header, data, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, b, got, i)
+	}
+}
+
+// FuzzLZMA compares decoding an LZMA stream, including the header, with the
+// reference decoder.
+func FuzzLZMA(f *testing.F) {
+	for i, eos := range []bool{true, false} {
+		header, data := compress(f, testData(int64(i), 1<<12), xlzma.Properties{LC: 3, LP: 0, PB: 2}, 1<<12, eos)
+		f.Add(append(append([]byte(nil), header...), data...))
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if len(b) < xlzma.HeaderLen {
+			return
+		}
+
+		// Keep the dictionary small enough to not run out of memory
+		if binary.LittleEndian.Uint32(b[1:]) > maxSize {
+			return
+		}
+
+		size := int64(binary.LittleEndian.Uint64(b[5:]))
+		if size > maxSize {
+			return
+		}
+
+		got, err := decompress(b, b[xlzma.HeaderLen:], size)
+
+		r, rerr := xlzma.NewReader(bytes.NewReader(b))
+		if rerr != nil || err != nil {
+			return
+		}
+
+		want, rerr := io.ReadAll(io.LimitReader(r, maxSize))
+		if rerr != nil {
+			return
+		}
+
+		assert.Equal(t, want, got)
+	})
+}
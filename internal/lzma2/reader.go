@@ -1,30 +1,240 @@
 package lzma2
 
 import (
+	"bufio"
+	"encoding/binary"
 	"errors"
 	"io"
+	"sync"
 
-	"github.com/ulikunitz/xz/lzma"
+	"github.com/bodgit/rvz/internal/lzma"
 )
 
-// NewReader returns a new LZMA2 io.ReadCloser.
+var (
+	errProperties = errors.New("lzma2: not enough properties")
+	errDictSize   = errors.New("lzma2: bad dictionary size")
+	errChunk      = errors.New("lzma2: bad chunk")
+)
+
+const (
+	maxDictProp = 40
+
+	minDictSize = 1 << 12
+)
+
+//nolint:gochecknoglobals
+var readerPool sync.Pool
+
+type readCloser struct {
+	d  lzma.Decoder
+	br *bufio.Reader
+
+	dictSize int
+
+	unpacked int  // remaining uncompressed bytes in the chunk
+	packed   int  // compressed size of the current LZMA chunk
+	lzma     bool // current chunk is LZMA compressed
+	needDict bool
+	needProp bool
+	eos      bool
+}
+
+func (rc *readCloser) Close() error {
+	rc.br.Reset(nil)
+	readerPool.Put(rc)
+
+	return nil
+}
+
+func (rc *readCloser) readUint16() (int, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(rc.br, b[:]); err != nil {
+		return 0, err
+	}
+
+	return int(binary.BigEndian.Uint16(b[:])), nil
+}
+
+// startChunk reads the next chunk header.
+//
+//nolint:cyclop,funlen
+func (rc *readCloser) startChunk() error {
+	control, err := rc.br.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case control == 0x00:
+		rc.eos = true
+
+		return io.EOF
+	case control == 0x01:
+		// The next LZMA chunk must reset the state and properties as
+		// the distances from the old dictionary are no longer valid
+		rc.d.ResetDict(rc.dictSize)
+		rc.needDict = false
+		rc.needProp = true
+	case control == 0x02:
+		if rc.needDict {
+			return errChunk
+		}
+	case control < 0x80:
+		return errChunk
+	}
+
+	rc.lzma = control >= 0x80
+
+	if !rc.lzma {
+		n, err := rc.readUint16()
+		if err != nil {
+			return err
+		}
+
+		rc.unpacked = n + 1
+
+		return nil
+	}
+
+	n, err := rc.readUint16()
+	if err != nil {
+		return err
+	}
+
+	rc.unpacked = int(control&0x1f)<<16 + n + 1
+
+	if n, err = rc.readUint16(); err != nil {
+		return err
+	}
+
+	rc.packed = n + 1
+
+	reset := (control >> 5) & 3
+
+	if reset == 3 {
+		rc.d.ResetDict(rc.dictSize)
+		rc.needDict = false
+		rc.needProp = true
+	} else if rc.needDict {
+		return errChunk
+	}
+
+	switch {
+	case reset >= 2:
+		b, err := rc.br.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		props, err := lzma.ParseProperties(b)
+		if err != nil {
+			return err
+		}
+
+		if props.LC+props.LP > 4 {
+			return errChunk
+		}
+
+		rc.d.ResetState(props)
+		rc.needProp = false
+	case rc.needProp:
+		return errChunk
+	case reset == 1:
+		rc.d.ResetState(rc.d.Properties())
+	}
+
+	return rc.d.ResetRange(rc.br)
+}
+
+func (rc *readCloser) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if rc.eos {
+			return n, io.EOF
+		}
+
+		if rc.unpacked == 0 {
+			if err = rc.startChunk(); err != nil {
+				if errors.Is(err, io.EOF) && !rc.eos {
+					err = io.ErrUnexpectedEOF
+				}
+
+				return n, err
+			}
+
+			continue
+		}
+
+		m := len(p) - n
+		if m > rc.unpacked {
+			m = rc.unpacked
+		}
+
+		if rc.lzma {
+			m, err = rc.d.Read(p[n : n+m])
+			if err != nil {
+				// An end marker isn't allowed within a chunk
+				if errors.Is(err, io.EOF) {
+					err = errChunk
+				}
+
+				return n + m, err
+			}
+		} else {
+			if m, err = io.ReadFull(rc.br, p[n:n+m]); err != nil {
+				if errors.Is(err, io.EOF) {
+					err = io.ErrUnexpectedEOF
+				}
+
+				return n + m, err
+			}
+
+			_, _ = rc.d.Write(p[n : n+m])
+		}
+
+		n += m
+		rc.unpacked -= m
+
+		if rc.lzma && rc.unpacked == 0 && rc.d.Consumed() != int64(rc.packed) {
+			return n, errChunk
+		}
+	}
+
+	return n, nil
+}
+
+// NewReader returns a new LZMA2 io.ReadCloser. The single property byte
+// encodes the dictionary size. Closing the reader returns it to a pool to be
+// reused.
 func NewReader(p []byte, reader io.Reader) (io.ReadCloser, error) {
 	if len(p) != 1 {
-		return nil, errors.New("lzma2: not enough properties")
+		return nil, errProperties
 	}
 
-	config := lzma.Reader2Config{
-		DictCap: (2 | (int(p[0]) & 1)) << (p[0]/2 + 11),
+	if p[0] > maxDictProp {
+		return nil, errDictSize
 	}
 
-	if err := config.Verify(); err != nil {
-		return nil, err
+	dictSize := int64(0xffffffff)
+	if p[0] < maxDictProp {
+		dictSize = int64(2|(p[0]&1)) << (p[0]/2 + 11)
 	}
 
-	r, err := config.NewReader2(reader)
-	if err != nil {
-		return nil, err
+	if dictSize < minDictSize {
+		dictSize = minDictSize
 	}
 
-	return io.NopCloser(r), nil
+	rc, ok := readerPool.Get().(*readCloser)
+	if ok {
+		rc.br.Reset(reader)
+	} else {
+		rc = &readCloser{br: bufio.NewReader(reader)}
+	}
+
+	rc.dictSize = int(dictSize)
+	rc.unpacked = 0
+	rc.needDict = true
+	rc.needProp = true
+	rc.eos = false
+
+	return rc, nil
 }
@@ -0,0 +1,422 @@
+package lzma2_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/bodgit/rvz/internal/lzma2"
+	"github.com/stretchr/testify/assert"
+	xlzma "github.com/ulikunitz/xz/lzma"
+)
+
+const (
+	testSize = 1 << 17
+
+	// maxSize is the most that is decoded from any stream
+	maxSize = 1 << 20
+
+	// minDictProp is the smallest dictionary size the reference encoder
+	// can use for data that doesn't compress, and maxDictProp is the
+	// largest dictionary size used by the fuzz target
+	minDictProp = 10
+	maxDictProp = 18
+)
+
+// The chunk types, ignoring the size bits of LZMA chunks.
+const (
+	ctrlEnd             = 0x00
+	ctrlUncompressedNew = 0x01 // resets the dictionary
+	ctrlUncompressed    = 0x02
+	ctrlLZMA            = 0x80
+	ctrlLZMAState       = 0xa0 // resets the state
+	ctrlLZMAProps       = 0xc0 // resets the state with new properties
+	ctrlLZMANew         = 0xe0 // also resets the dictionary
+)
+
+// testData returns n bytes made up of text, runs of zeroes, random bytes and
+// copies of earlier data, which compress to more than one LZMA chunk. If
+// random is set they are only random bytes, which won't compress at all.
+func testData(seed int64, n int, random bool) []byte {
+	words := []string{"nintendo ", "gamecube ", "wii ", "disc ", "sector ", "cluster ", "junk ", "\n"}
+	r := rand.New(rand.NewSource(seed)) //nolint:gosec
+	b := make([]byte, 0, n)
+
+	if random {
+		b = b[:n]
+		_, _ = r.Read(b)
+
+		return b
+	}
+
+	for len(b) < n {
+		switch r.Intn(4) {
+		case 0:
+			for i := r.Intn(50); i >= 0; i-- {
+				b = append(b, words[r.Intn(len(words))]...)
+			}
+		case 1:
+			b = append(b, make([]byte, r.Intn(200))...)
+		case 2:
+			for i := r.Intn(500); i >= 0; i-- {
+				b = append(b, byte(r.Intn(256)))
+			}
+		case 3:
+			if len(b) == 0 {
+				continue
+			}
+
+			start := r.Intn(len(b))
+			for i := r.Intn(300); i >= 0; i-- {
+				b = append(b, b[start+i%(len(b)-start)])
+			}
+		}
+	}
+
+	return b[:n]
+}
+
+func dictSize(p byte) int {
+	return int(2|(p&1)) << (p/2 + 11)
+}
+
+// compress returns the LZMA2 chunks of b, without the end marker.
+func compress(tb testing.TB, b []byte, props xlzma.Properties, p byte) []byte {
+	tb.Helper()
+
+	buf := new(bytes.Buffer)
+
+	w, err := xlzma.Writer2Config{Properties: &props, DictCap: dictSize(p)}.NewWriter2(buf)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if _, err = w.Write(b); err != nil {
+		tb.Fatal(err)
+	}
+
+	if err = w.Close(); err != nil {
+		tb.Fatal(err)
+	}
+
+	return buf.Bytes()[:buf.Len()-1]
+}
+
+// resetState changes the first chunk of c, which must be an LZMA chunk that
+// resets the dictionary, to only reset the state, keeping the properties
+// if props is set. The data before it must end with a zero byte and be a
+// multiple of 16 bytes long so the literals are decoded the same.
+func resetState(tb testing.TB, c []byte, props bool) []byte {
+	tb.Helper()
+
+	if c[0]&0xe0 != ctrlLZMANew {
+		tb.Fatalf("first chunk is %#02x", c[0])
+	}
+
+	if props {
+		return append([]byte{ctrlLZMAProps | c[0]&0x1f}, c[1:]...)
+	}
+
+	// Drop the properties byte too
+	return append(append([]byte{ctrlLZMAState | c[0]&0x1f}, c[1:5]...), c[6:]...)
+}
+
+// controls returns the type of each chunk in c.
+func controls(c []byte) []byte {
+	var types []byte
+
+	for len(c) > 0 && c[0] != ctrlEnd {
+		switch control := c[0]; {
+		case control < ctrlLZMA:
+			types = append(types, control)
+			c = c[3+int(binary.BigEndian.Uint16(c[1:]))+1:]
+		case control >= ctrlLZMAProps:
+			types = append(types, control&0xe0)
+			c = c[6+int(binary.BigEndian.Uint16(c[3:]))+1:]
+		default:
+			types = append(types, control&0xe0)
+			c = c[5+int(binary.BigEndian.Uint16(c[3:]))+1:]
+		}
+	}
+
+	return types
+}
+
+// testStream returns an LZMA2 stream and the data it decodes to.
+type testStream func(tb testing.TB, props xlzma.Properties, p byte) ([]byte, []byte)
+
+// testStreams returns streams covering each type of chunk, using parts of n
+// bytes.
+//
+//nolint:funlen
+func testStreams(n int) []struct {
+	name  string
+	types []byte
+	fn    testStream
+} {
+	// Properties used by a chunk that changes them
+	other := xlzma.Properties{LC: 0, LP: 2, PB: 0}
+
+	// The data before a chunk that keeps the dictionary but resets
+	// the state
+	first := testData(1, n, false)
+	first[len(first)-1] = 0
+
+	return []struct {
+		name  string
+		types []byte
+		fn    testStream
+	}{
+		{
+			name:  "LZMA",
+			types: []byte{ctrlLZMANew, ctrlLZMA},
+			fn: func(tb testing.TB, props xlzma.Properties, p byte) ([]byte, []byte) {
+				b := testData(2, n, false)
+
+				return compress(tb, b, props, p), b
+			},
+		},
+		{
+			name:  "Uncompressed",
+			types: []byte{ctrlUncompressedNew, ctrlUncompressed},
+			fn: func(tb testing.TB, props xlzma.Properties, p byte) ([]byte, []byte) {
+				b := testData(3, n, true)
+
+				return compress(tb, b, props, p), b
+			},
+		},
+		{
+			name:  "Mixed",
+			types: []byte{ctrlUncompressedNew, ctrlLZMAProps, ctrlLZMA},
+			fn: func(tb testing.TB, props xlzma.Properties, p byte) ([]byte, []byte) {
+				b := append(testData(4, n, true), testData(5, n, false)...)
+
+				return compress(tb, b, props, p), b
+			},
+		},
+		{
+			name:  "ResetDict",
+			types: []byte{ctrlLZMANew, ctrlLZMA, ctrlLZMANew},
+			fn: func(tb testing.TB, props xlzma.Properties, p byte) ([]byte, []byte) {
+				b := testData(6, n, false)
+
+				return append(compress(tb, first, props, p), compress(tb, b, other, p)...), append(first, b...)
+			},
+		},
+		{
+			name:  "ResetDictUncompressed",
+			types: []byte{ctrlLZMANew, ctrlUncompressedNew},
+			fn: func(tb testing.TB, props xlzma.Properties, p byte) ([]byte, []byte) {
+				b := testData(7, n, true)
+
+				return append(compress(tb, first, props, p), compress(tb, b, props, p)...), append(first, b...)
+			},
+		},
+		{
+			name:  "ResetState",
+			types: []byte{ctrlLZMANew, ctrlLZMAState},
+			fn: func(tb testing.TB, props xlzma.Properties, p byte) ([]byte, []byte) {
+				b := testData(8, n, false)
+				c := resetState(tb, compress(tb, b, props, p), false)
+
+				return append(compress(tb, first, props, p), c...), append(first, b...)
+			},
+		},
+		{
+			name:  "ResetProps",
+			types: []byte{ctrlLZMANew, ctrlLZMAProps},
+			fn: func(tb testing.TB, props xlzma.Properties, p byte) ([]byte, []byte) {
+				b := testData(9, n, false)
+				c := resetState(tb, compress(tb, b, other, p), true)
+
+				return append(compress(tb, first, props, p), c...), append(first, b...)
+			},
+		},
+	}
+}
+
+// decompress decodes the LZMA2 stream c, but never more than maxSize bytes.
+func decompress(p byte, c []byte) ([]byte, error) {
+	rc, err := lzma2.NewReader([]byte{p}, bytes.NewReader(c))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(io.LimitReader(rc, maxSize))
+}
+
+//nolint:funlen
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		stream string
+		fn     testStream
+		props  xlzma.Properties
+		p      byte
+	}
+
+	var tables []testCase
+
+	// Every combination of properties and dictionary size for a plain
+	// stream, and the default properties for the rest
+	for i, stream := range testStreams(testSize) {
+		for _, props := range []xlzma.Properties{
+			{LC: 3, LP: 0, PB: 2},
+			{LC: 0, LP: 4, PB: 0},
+			{LC: 4, LP: 0, PB: 4},
+			{LC: 1, LP: 1, PB: 1},
+		} {
+			for _, p := range []byte{minDictProp, 14, maxDictProp} {
+				tables = append(tables, testCase{stream.name, stream.fn, props, p})
+			}
+
+			if i > 0 {
+				break
+			}
+		}
+	}
+
+	for _, table := range tables {
+		table := table
+		name := fmt.Sprintf("%s/LC%dLP%dPB%d/Dict%d", table.stream, table.props.LC, table.props.LP, table.props.PB,
+			dictSize(table.p))
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c, b := table.fn(t, table.props, table.p)
+			c = append(c, ctrlEnd)
+
+			r, err := xlzma.Reader2Config{DictCap: dictSize(table.p)}.NewReader2(bytes.NewReader(c))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := decompress(table.p, c)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+			assert.Equal(t, b, got)
+
+			// Without the end marker
+			_, err = decompress(table.p, c[:len(c)-1])
+			assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		})
+	}
+}
+
+// TestChunks checks each stream has the chunk types it is meant to test.
+func TestChunks(t *testing.T) {
+	t.Parallel()
+
+	for _, stream := range testStreams(testSize) {
+		c, _ := stream.fn(t, xlzma.Properties{LC: 3, LP: 0, PB: 2}, minDictProp)
+
+		types := controls(c)
+		for _, control := range stream.types {
+			assert.Contains(t, types, control, stream.name)
+		}
+	}
+}
+
+// TestReuse checks a reader returned to the pool, even part way through a
+// stream, decodes the next stream correctly whatever its dictionary size.
+//
+//nolint:paralleltest
+func TestReuse(t *testing.T) {
+	props := xlzma.Properties{LC: 3, LP: 0, PB: 2}
+
+	for i, stream := range testStreams(testSize) {
+		p := []byte{maxDictProp, minDictProp, 14}[i%3]
+
+		c, b := stream.fn(t, props, p)
+		c = append(c, ctrlEnd)
+
+		rc, err := lzma2.NewReader([]byte{p}, bytes.NewReader(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Abandon the first reader part way through
+		_, err = io.CopyN(io.Discard, rc, int64(len(b)/3))
+		assert.NoError(t, err)
+		assert.NoError(t, rc.Close())
+
+		got, err := decompress(p, c)
+		assert.NoError(t, err)
+		assert.Equal(t, b, got, stream.name)
+	}
+}
+
+// staleReps is an LZMA chunk that fills the dictionary, then an uncompressed
+// chunk that resets it, followed by an LZMA chunk that keeps the state and
+// so refers to the distances used before the reset.
+const staleReps = "e1ffff00615d00309888983ecbe26f3b50fc64a03fffec2691c0f5159decc6ae7e0db6fdea301abb213aeff919" +
+	"bddb9ee19a56038b47571ccc46a34eca29415db7a1dc7108a81a9f7a7f176452f4f85f3fff480e34aad661bbf70c20" +
+	"5b0c765f9d17a7ca923c00000100007880000f000f0066c74d10037c4d7bbb0407d1e2c64900"
+
+func TestStaleReps(t *testing.T) {
+	t.Parallel()
+
+	b, err := hex.DecodeString(staleReps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := lzma2.NewReader([]byte{16}, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	_, err = io.Copy(io.Discard, rc)
+	assert.Error(t, err)
+}
+
+// FuzzLZMA2 compares decoding an LZMA2 stream with the reference decoder.
+func FuzzLZMA2(f *testing.F) {
+	props := xlzma.Properties{LC: 3, LP: 0, PB: 2}
+
+	for _, stream := range testStreams(1 << 12) {
+		c, _ := stream.fn(f, props, minDictProp)
+		f.Add(byte(minDictProp), append(c, ctrlEnd))
+	}
+
+	b, err := hex.DecodeString(staleReps)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(byte(16), b)
+
+	f.Fuzz(func(t *testing.T, p byte, b []byte) {
+		// Keep the dictionary small enough to not run out of memory
+		if p > maxDictProp {
+			return
+		}
+
+		got, err := decompress(p, b)
+
+		r, rerr := xlzma.Reader2Config{DictCap: dictSize(p)}.NewReader2(bytes.NewReader(b))
+		if rerr != nil || err != nil {
+			return
+		}
+
+		want, rerr := io.ReadAll(io.LimitReader(r, maxSize))
+		if rerr != nil {
+			return
+		}
+
+		assert.Equal(t, want, got)
+	})
+}
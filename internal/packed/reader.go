@@ -1,13 +1,11 @@
 package packed
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"io"
 	"sync"
 
-	"github.com/bodgit/plumbing"
 	"github.com/bodgit/rvz/internal/padding"
 )
 
@@ -20,100 +18,78 @@ var pool sync.Pool //nolint:gochecknoglobals
 
 type readCloser struct {
 	rc     io.ReadCloser
-	src    io.ReadCloser
+	pad    padding.Generator
+	padded bool
 	size   int64
-	buf    *bytes.Buffer
 	offset int64
+	hdr    [4]byte
 }
 
-func (rc *readCloser) nextReader() (err error) {
-	var size uint32
-	if err = binary.Read(rc.rc, binary.BigEndian, &size); err != nil {
+func (rc *readCloser) nextReader() error {
+	if _, err := io.ReadFull(rc.rc, rc.hdr[:]); err != nil {
 		return err
 	}
 
+	size := binary.BigEndian.Uint32(rc.hdr[:])
+
 	rc.size = int64(size & sizeMask)
+	rc.padded = size&padded == padded
+
+	if rc.padded {
+		if err := rc.pad.Reset(rc.rc, rc.offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
 
-	if size&padded == padded {
-		nrc, err := padding.NewReadCloser(rc.rc, rc.offset)
-		if err != nil {
 			return err
 		}
-
-		rc.src = plumbing.LimitReadCloser(nrc, rc.size)
-	} else {
-		// Intentionally "hide" the underlying Close method
-		rc.src = io.NopCloser(io.LimitReader(rc.rc, rc.size))
 	}
 
 	return nil
 }
 
-//nolint:nakedret
-func (rc *readCloser) read() (err error) {
-	for {
-		if rc.size == 0 {
-			if err = rc.nextReader(); err != nil {
-				if errors.Is(err, io.EOF) {
-					return nil
-				}
-
-				return
-			}
-		}
-
-		var (
-			n         int64
-			remaining = int64(rc.buf.Cap() - rc.buf.Len())
-		)
-
-		if remaining >= rc.size {
-			n, err = io.Copy(rc.buf, rc.src)
-		} else {
-			n, err = io.CopyN(rc.buf, rc.src, remaining)
+func (rc *readCloser) Read(p []byte) (int, error) {
+	for rc.size == 0 {
+		if err := rc.nextReader(); err != nil {
+			return 0, err
 		}
+	}
 
-		if err != nil {
-			return
-		}
+	if int64(len(p)) > rc.size {
+		p = p[:rc.size]
+	}
 
-		rc.size -= n
-		rc.offset += n
+	var (
+		n   int
+		err error
+	)
 
-		if rc.size == 0 {
-			if err = rc.src.Close(); err != nil {
-				return
+	if rc.padded {
+		n, err = rc.pad.Read(p)
+	} else {
+		n, err = rc.rc.Read(p)
+		if errors.Is(err, io.EOF) {
+			if int64(n) < rc.size {
+				err = io.ErrUnexpectedEOF
+			} else {
+				err = nil
 			}
-
-			rc.src = nil
-		}
-
-		if rc.buf.Len() == rc.buf.Cap() {
-			break
 		}
 	}
 
-	return nil
-}
-
-func (rc *readCloser) Read(p []byte) (int, error) {
-	if err := rc.read(); err != nil && !errors.Is(err, io.EOF) {
-		return 0, err
-	}
+	rc.size -= int64(n)
+	rc.offset += int64(n)
 
-	return rc.buf.Read(p)
+	return n, err
 }
 
-func (rc *readCloser) Close() (err error) {
-	pool.Put(rc.buf)
+func (rc *readCloser) Close() error {
+	err := rc.rc.Close()
 
-	if rc.src != nil {
-		if err = rc.src.Close(); err != nil {
-			return
-		}
-	}
+	rc.rc = nil
+	pool.Put(rc)
 
-	return rc.rc.Close()
+	return err
 }
 
 // NewReadCloser returns a new io.ReadCloser that reads the RVZ packed stream
@@ -121,20 +97,46 @@ func (rc *readCloser) Close() (err error) {
 // starts relative to the beginning of the uncompressed disc image is also
 // required.
 func NewReadCloser(rc io.ReadCloser, offset int64) (io.ReadCloser, error) {
-	nrc := &readCloser{
-		rc:     rc,
-		offset: offset,
-	}
-
-	b, ok := pool.Get().(*bytes.Buffer)
-	if ok {
-		b.Reset()
-	} else {
-		b = new(bytes.Buffer)
-		b.Grow(1 << 16)
+	nrc, ok := pool.Get().(*readCloser)
+	if !ok {
+		nrc = new(readCloser)
 	}
 
-	nrc.buf = b
+	nrc.rc = rc
+	nrc.size = 0
+	nrc.offset = offset
 
 	return nrc, nil
 }
+
+// Junk walks the RVZ packed stream read from r and returns how many bytes
+// of it are padding generated from a PRNG seed rather than stored.
+func Junk(r io.Reader) (junk int64, err error) {
+	var hdr [4]byte
+
+	for {
+		if _, err = io.ReadFull(r, hdr[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return junk, nil
+			}
+
+			return
+		}
+
+		size := binary.BigEndian.Uint32(hdr[:])
+
+		skip := int64(size & sizeMask)
+		if size&padded == padded {
+			junk += skip
+			skip = padding.SeedSize
+		}
+
+		if _, err = io.CopyN(io.Discard, r, skip); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package packed_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"github.com/bodgit/rvz/internal/packed"
+	"github.com/bodgit/rvz/internal/padding"
+	"github.com/bodgit/rvz/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// run is either literal data or, if seed is set, size bytes of padding.
+type run struct {
+	size int
+	seed bool
+}
+
+// testPacked returns a packed stream of runs starting at offset in the disc
+// image and the data it unpacks to.
+func testPacked(tb testing.TB, offset int64, runs []run) ([]byte, []byte) {
+	tb.Helper()
+
+	r := rand.New(rand.NewSource(1)) //nolint:gosec
+
+	var stream, data []byte
+
+	for _, run := range runs {
+		b := make([]byte, run.size)
+		_, _ = r.Read(b)
+
+		if !run.seed {
+			stream = binary.BigEndian.AppendUint32(stream, uint32(run.size))
+			stream = append(stream, b...)
+			data = append(data, b...)
+
+			continue
+		}
+
+		seed := make([]byte, padding.SeedSize)
+		_, _ = r.Read(seed)
+
+		rc, err := padding.NewReadCloser(bytes.NewReader(seed), offset+int64(len(data)))
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		if _, err = io.ReadFull(rc, b); err != nil {
+			tb.Fatal(err)
+		}
+
+		rc.Close()
+
+		stream = binary.BigEndian.AppendUint32(stream, uint32(run.size)|1<<31)
+		stream = append(stream, seed...)
+		data = append(data, b...)
+	}
+
+	return stream, data
+}
+
+// testRuns are junk runs starting part way through a sector, with literal
+// runs between them.
+func testRuns() []run {
+	return []run{
+		{0x100, false},
+		{util.SectorSize - 0x100, true},
+		{0x1233, true},
+		{3, false},
+		{util.SectorSize - 0x1236, true},
+		{util.SectorSize, false},
+		{0x7001, true},
+	}
+}
+
+//nolint:funlen
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name   string
+		offset int64
+		size   int // size of each Read, or zero to read everything at once
+		wrap   func(io.Reader) io.Reader
+	}{
+		{name: "ReadAll"},
+		{name: "Offset", offset: 3 * util.SectorSize},
+		{name: "Read1", size: 1},
+		{name: "Read3", size: 3},
+		{name: "Read999", size: 999, offset: util.SectorSize},
+		{name: "Read2084", size: 2084},
+		{name: "OneByteReader", size: 999, wrap: iotest.OneByteReader},
+		{name: "HalfReader", size: 999, wrap: iotest.HalfReader},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream, want := testPacked(t, table.offset, testRuns())
+
+			var r io.Reader = bytes.NewReader(stream)
+			if table.wrap != nil {
+				r = table.wrap(r)
+			}
+
+			rc, err := packed.NewReadCloser(io.NopCloser(r), table.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+
+			var got []byte
+
+			if table.size == 0 {
+				got, err = io.ReadAll(rc)
+				assert.NoError(t, err)
+				assert.True(t, bytes.Equal(want, got))
+
+				return
+			}
+
+			buf := make([]byte, table.size)
+
+			for {
+				n, err := rc.Read(buf)
+				got = append(got, buf[:n]...)
+
+				if err != nil {
+					assert.ErrorIs(t, err, io.EOF)
+
+					break
+				}
+			}
+
+			assert.True(t, bytes.Equal(want, got))
+		})
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	t.Parallel()
+
+	stream, _ := testPacked(t, 0, []run{{0x100, false}, {0x100, true}})
+
+	for _, n := range []int{2, 4 + 0x80, 4 + 0x100 + 4 + 0x10} {
+		n := n
+
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			t.Parallel()
+
+			rc, err := packed.NewReadCloser(io.NopCloser(bytes.NewReader(stream[:n])), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+
+			_, err = io.ReadAll(rc)
+			assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		})
+	}
+}
+
+func TestJunk(t *testing.T) {
+	t.Parallel()
+
+	stream, _ := testPacked(t, 0, testRuns())
+
+	var want int64
+
+	for _, run := range testRuns() {
+		if run.seed {
+			want += int64(run.size)
+		}
+	}
+
+	junk, err := packed.Junk(bytes.NewReader(stream))
+	assert.NoError(t, err)
+	assert.Equal(t, want, junk)
+
+	_, err = packed.Junk(bytes.NewReader(stream[:len(stream)-1]))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func BenchmarkReader(b *testing.B) {
+	stream, data := testPacked(b, 0, testRuns())
+	buf := make([]byte, len(data))
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		rc, err := packed.NewReadCloser(io.NopCloser(bytes.NewReader(stream)), 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err = io.ReadFull(rc, buf); err != nil {
+			b.Fatal(err)
+		}
+
+		rc.Close()
+	}
+}
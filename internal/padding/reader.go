@@ -1,7 +1,6 @@
 package padding
 
 import (
-	"bytes"
 	"encoding/binary"
 	"io"
 	"sync"
@@ -12,40 +11,103 @@ import (
 const (
 	initialSize = 17
 	maximumSize = 521
+
+	// SeedSize is how many bytes are read to seed the PRNG.
+	SeedSize = initialSize * 4
+
+	// blockSize is how many bytes of padding are generated from each
+	// state of the PRNG.
+	blockSize = maximumSize * 4
 )
 
-//nolint:gochecknoglobals
-var prngPool, bufPool sync.Pool
+var pool sync.Pool //nolint:gochecknoglobals
 
-type readCloser struct {
-	prng []uint32
-	buf  *bytes.Buffer
+// A Generator generates a stream of GameCube and Wii padding data. The zero
+// value is not usable until Reset is called.
+type Generator struct {
+	prng [maximumSize]uint32
+	buf  [blockSize]byte
+	pos  int
 }
 
-func (rc *readCloser) advance() {
-	for i := range rc.prng {
-		rc.prng[i] ^= rc.prng[(i+len(rc.prng)-32)%len(rc.prng)]
+func (g *Generator) advance() {
+	for i := 0; i < 32; i++ {
+		g.prng[i] ^= g.prng[i+maximumSize-32]
+	}
+
+	for i := 32; i < maximumSize; i++ {
+		g.prng[i] ^= g.prng[i-32]
+	}
+}
+
+func (g *Generator) fill() {
+	for i, x := range g.prng {
+		// The second byte is taken from bits 18-25, not 16-23
+		binary.BigEndian.PutUint32(g.buf[i*4:], x&0xff00ffff|x>>2&0x00ff0000)
+	}
+
+	g.advance()
+	g.pos = 0
+}
+
+// Reset seeds the PRNG from the io.Reader r and then skips forward to offset,
+// which is relative to the beginning of the uncompressed disc image or the
+// partition. Padding restarts at every sector boundary so only the offset
+// within the sector matters.
+func (g *Generator) Reset(r io.Reader, offset int64) error {
+	seed := g.buf[:SeedSize]
+	if _, err := io.ReadFull(r, seed); err != nil {
+		return err
+	}
+
+	for i := 0; i < initialSize; i++ {
+		g.prng[i] = binary.BigEndian.Uint32(seed[i*4:])
+	}
+
+	for i := initialSize; i < maximumSize; i++ {
+		g.prng[i] = g.prng[i-17]<<23 ^ g.prng[i-16]>>9 ^ g.prng[i-1]
+	}
+
+	for i := 0; i < 4; i++ {
+		g.advance()
+	}
+
+	offset %= util.SectorSize
+
+	// Skip whole blocks without generating their output
+	for i := offset / blockSize; i > 0; i-- {
+		g.advance()
 	}
+
+	g.fill()
+	g.pos = int(offset % blockSize)
+
+	return nil
 }
 
-func (rc *readCloser) Read(p []byte) (int, error) {
-	if rc.buf.Len() == 0 {
-		for _, x := range rc.prng {
-			_ = rc.buf.WriteByte(byte(0xff & (x >> 24)))
-			_ = rc.buf.WriteByte(byte(0xff & (x >> 18))) // not 16!
-			_ = rc.buf.WriteByte(byte(0xff & (x >> 8)))
-			_ = rc.buf.WriteByte(byte(0xff & (x)))
+// Read fills p with padding data. It never returns an error.
+func (g *Generator) Read(p []byte) (int, error) {
+	n := 0
+
+	for n < len(p) {
+		if g.pos == blockSize {
+			g.fill()
 		}
 
-		rc.advance()
+		m := copy(p[n:], g.buf[g.pos:])
+		g.pos += m
+		n += m
 	}
 
-	return rc.buf.Read(p)
+	return n, nil
+}
+
+type readCloser struct {
+	Generator
 }
 
 func (rc *readCloser) Close() error {
-	prngPool.Put(&rc.prng)
-	bufPool.Put(rc.buf)
+	pool.Put(rc)
 
 	return nil
 }
@@ -55,41 +117,14 @@ func (rc *readCloser) Close() error {
 // where this padded stream starts relative to the beginning of the
 // uncompressed disc image or the partition is also required.
 func NewReadCloser(r io.Reader, offset int64) (io.ReadCloser, error) {
-	rc := new(readCloser)
-
-	p, ok := prngPool.Get().(*[]uint32)
-	if ok {
-		rc.prng = *p
-		rc.prng = rc.prng[:initialSize]
-	} else {
-		rc.prng = make([]uint32, initialSize, maximumSize)
+	rc, ok := pool.Get().(*readCloser)
+	if !ok {
+		rc = new(readCloser)
 	}
 
-	if err := binary.Read(r, binary.BigEndian, rc.prng); err != nil {
-		return nil, err
-	}
-
-	rc.prng = rc.prng[:maximumSize]
-
-	b, ok := bufPool.Get().(*bytes.Buffer)
-	if ok {
-		b.Reset()
-	} else {
-		b = new(bytes.Buffer)
-		b.Grow(maximumSize << 2)
-	}
-
-	rc.buf = b
-
-	for i := initialSize; i < maximumSize; i++ {
-		rc.prng[i] = rc.prng[i-17]<<23 ^ rc.prng[i-16]>>9 ^ rc.prng[i-1]
-	}
-
-	for i := 0; i < 4; i++ {
-		rc.advance()
-	}
+	if err := rc.Reset(r, offset); err != nil {
+		pool.Put(rc)
 
-	if _, err := io.CopyN(io.Discard, rc, offset%util.SectorSize); err != nil {
 		return nil, err
 	}
 
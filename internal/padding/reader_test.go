@@ -0,0 +1,115 @@
+package padding_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/bodgit/rvz/internal/padding"
+	"github.com/bodgit/rvz/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSeed() []byte {
+	seed := make([]byte, padding.SeedSize)
+	_, _ = rand.New(rand.NewSource(1)).Read(seed) //nolint:gosec
+
+	return seed
+}
+
+// sector returns a whole sector of padding generated from the start.
+func sector(tb testing.TB, seed []byte) []byte {
+	tb.Helper()
+
+	var g padding.Generator
+	if err := g.Reset(bytes.NewReader(seed), 0); err != nil {
+		tb.Fatal(err)
+	}
+
+	b := make([]byte, util.SectorSize)
+	_, _ = g.Read(b)
+
+	return b
+}
+
+func TestReset(t *testing.T) {
+	t.Parallel()
+
+	seed := testSeed()
+	want := sector(t, seed)
+
+	// Each state of the PRNG generates 2084 bytes
+	for _, offset := range []int64{
+		0, 1, 2, 3, 4, 5, 7,
+		2083, 2084, 2085, 2087,
+		4167, 4168, 4170,
+		0x7ffc, 0x7fff,
+		util.SectorSize, util.SectorSize + 1, util.SectorSize + 2086,
+		5*util.SectorSize + 0x1233,
+	} {
+		offset := offset
+
+		t.Run(fmt.Sprint(offset), func(t *testing.T) {
+			t.Parallel()
+
+			start := offset % util.SectorSize
+
+			var g padding.Generator
+			if err := g.Reset(bytes.NewReader(seed), offset); err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]byte, util.SectorSize-start)
+			_, _ = g.Read(got)
+			assert.Equal(t, want[start:], got)
+
+			rc, err := padding.NewReadCloser(bytes.NewReader(seed), offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+
+			// Read in odd sized pieces that don't line up with the
+			// output of each PRNG state
+			got = make([]byte, len(got))
+
+			for i := 0; i < len(got); i += 999 {
+				end := i + 999
+				if end > len(got) {
+					end = len(got)
+				}
+
+				_, _ = rc.Read(got[i:end])
+			}
+
+			assert.Equal(t, want[start:], got)
+		})
+	}
+}
+
+func TestShortSeed(t *testing.T) {
+	t.Parallel()
+
+	_, err := padding.NewReadCloser(bytes.NewReader(testSeed()[:padding.SeedSize-1]), 0)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func BenchmarkGenerator(b *testing.B) {
+	seed := testSeed()
+	buf := make([]byte, util.SectorSize)
+
+	var g padding.Generator
+
+	b.SetBytes(util.SectorSize)
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := g.Reset(bytes.NewReader(seed), 0); err != nil {
+			b.Fatal(err)
+		}
+
+		_, _ = g.Read(buf)
+	}
+}
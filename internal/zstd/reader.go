@@ -2,12 +2,15 @@ package zstd
 
 import (
 	"io"
-	"runtime"
 	"sync"
 
 	"github.com/klauspost/compress/zstd"
 )
 
+// maxWindowSize is larger than any group or table that needs to be
+// decompressed, but stops a corrupt frame header from demanding more.
+const maxWindowSize = 1 << 24 // 16 MiB
+
 //nolint:gochecknoglobals
 var zstdReaderPool sync.Pool
 
@@ -16,7 +19,7 @@ type readCloser struct {
 }
 
 func (rc *readCloser) Close() error {
-	zstdReaderPool.Put(rc)
+	zstdReaderPool.Put(rc.Decoder)
 
 	return nil
 }
@@ -31,11 +34,12 @@ func NewReader(_ []byte, reader io.Reader) (io.ReadCloser, error) {
 			return nil, err
 		}
 	} else {
-		if r, err = zstd.NewReader(reader); err != nil {
+		// Decoding synchronously means no goroutines are started so
+		// there's nothing to clean up if the decoder is dropped
+		r, err = zstd.NewReader(reader, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxWindowSize))
+		if err != nil {
 			return nil, err
 		}
-
-		runtime.SetFinalizer(r, (*zstd.Decoder).Close)
 	}
 
 	return &readCloser{r}, nil
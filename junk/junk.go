@@ -0,0 +1,147 @@
+// Package junk generates the junk data that Nintendo's mastering tools use
+// to fill the unused space on GameCube and Wii discs, and finds it in disc
+// images or in the decrypted data of Wii partitions.
+//
+// Junk data comes from a lagged Fibonacci generator that is seeded afresh at
+// the start of every 32 KiB sector, using the game ID, the disc number and
+// the index of the sector.
+package junk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/bodgit/rvz/internal/padding"
+	"github.com/bodgit/rvz/internal/util"
+)
+
+const (
+	// SectorSize is how often the generator is seeded again.
+	SectorSize = util.SectorSize
+
+	// SeedSize is the size of the seed for each sector.
+	SeedSize = padding.SeedSize
+
+	// HeaderSize is how much of the disc header ParseDisc needs.
+	HeaderSize = 0x20
+
+	wiiMagicOffset = 0x18
+	wiiMagic       = 0x5d1c9ea3
+)
+
+var (
+	// ErrHeader is returned when the disc header is too short.
+	ErrHeader = errors.New("junk: short disc header")
+)
+
+// A Disc identifies the disc that junk data is generated for.
+type Disc struct {
+	// ID is the four character game code at the start of the disc
+	// header, such as "GALE".
+	ID [4]byte
+	// Number is the disc number, zero for the first disc.
+	Number int
+}
+
+// ParseDisc returns the Disc described by the header at the start of a disc
+// image, or at the start of the decrypted data of a Wii partition, which
+// must be at least HeaderSize bytes.
+func ParseDisc(header []byte) (Disc, error) {
+	var d Disc
+
+	if len(header) < HeaderSize {
+		return d, ErrHeader
+	}
+
+	copy(d.ID[:], header)
+	d.Number = int(header[6])
+
+	return d, nil
+}
+
+// IsWii reports whether header, which must be at least HeaderSize bytes, is
+// the header of a Wii disc. Most of the junk data on a Wii disc is inside the
+// encrypted partitions, so each one needs to be decrypted and scanned with
+// the Disc from its own header.
+func IsWii(header []byte) bool {
+	return len(header) >= HeaderSize && binary.BigEndian.Uint32(header[wiiMagicOffset:]) == wiiMagic
+}
+
+// Seed returns the seed used for the junk data in the given sector, counting
+// from the start of the disc image.
+func (d Disc) Seed(sector int64) (seed [SeedSize]byte) {
+	// The bytes of the game ID are shuffled and summed rather than used
+	// as they are
+	id := binary.BigEndian.Uint32([]byte{d.ID[2], d.ID[1], d.ID[3] + d.ID[2], d.ID[0] + d.ID[1]})
+	x := (id^uint32(d.Number))*0x260bcd5 ^ uint32(sector)*0x1ef29123
+
+	var words [SeedSize / 4]uint32
+
+	for i := range words {
+		var sample uint32
+
+		for j := 0; j < 32; j++ {
+			x = x*0x5d588b65 + 1
+			sample = sample>>1 | x&0x80000000
+		}
+
+		words[i] = sample
+	}
+
+	words[len(words)-1] ^= words[0]>>9 ^ words[len(words)-1]<<23
+
+	for i, w := range words {
+		binary.BigEndian.PutUint32(seed[i*4:], w)
+	}
+
+	return
+}
+
+// A Reader generates the junk data of a disc. It never returns an error.
+type Reader struct {
+	d      Disc
+	offset int64
+	seeded bool
+	seed   [SeedSize]byte
+	br     bytes.Reader
+	g      padding.Generator
+}
+
+// NewReader returns a Reader that generates the junk data of disc d starting
+// at offset in the disc image.
+func (d Disc) NewReader(offset int64) *Reader {
+	return &Reader{d: d, offset: offset}
+}
+
+func (r *Reader) reseed() {
+	r.seed = r.d.Seed(r.offset / SectorSize)
+	r.br.Reset(r.seed[:])
+
+	// The seed is always long enough, so this can't fail
+	_ = r.g.Reset(&r.br, r.offset)
+	r.seeded = true
+}
+
+// Read fills p with junk data.
+func (r *Reader) Read(p []byte) (int, error) {
+	n := 0
+
+	for n < len(p) {
+		if !r.seeded || r.offset%SectorSize == 0 {
+			r.reseed()
+		}
+
+		m := len(p) - n
+		if left := SectorSize - int(r.offset%SectorSize); m > left {
+			m = left
+		}
+
+		_, _ = r.g.Read(p[n : n+m])
+
+		n += m
+		r.offset += int64(m)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,211 @@
+package junk_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/bodgit/rvz/internal/padding"
+	"github.com/bodgit/rvz/junk"
+	"github.com/stretchr/testify/assert"
+)
+
+//nolint:gochecknoglobals
+var testDisc = junk.Disc{ID: [4]byte{'G', 'A', 'L', 'E'}, Number: 1}
+
+func generate(tb testing.TB, offset int64, n int) []byte {
+	tb.Helper()
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(testDisc.NewReader(offset), b); err != nil {
+		tb.Fatal(err)
+	}
+
+	return b
+}
+
+func TestParseDisc(t *testing.T) {
+	t.Parallel()
+
+	header := make([]byte, junk.HeaderSize)
+	copy(header, "GALE01\x01\x00")
+
+	d, err := junk.ParseDisc(header)
+	assert.NoError(t, err)
+	assert.Equal(t, testDisc, d)
+
+	assert.False(t, junk.IsWii(header))
+
+	_, err = junk.ParseDisc(header[:8])
+	assert.ErrorIs(t, err, junk.ErrHeader)
+
+	copy(header, "RSPE01\x00\x00")
+	binary.BigEndian.PutUint32(header[0x18:], 0x5d1c9ea3)
+
+	d, err = junk.ParseDisc(header)
+	assert.NoError(t, err)
+	assert.Equal(t, junk.Disc{ID: [4]byte{'R', 'S', 'P', 'E'}}, d)
+	assert.True(t, junk.IsWii(header))
+	assert.False(t, junk.IsWii(header[:8]))
+}
+
+// TestSeed checks the seeds and the junk data generated from them against
+// values computed with a separate implementation of the algorithm used by
+// Dolphin and nod, rather than taken from a real disc.
+func TestSeed(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		id     string
+		number int
+		sector int64
+		seed   string
+		junk   string // the first 16 bytes of the sector
+		at1000 string // 16 bytes at 0x1000 in the sector
+	}{
+		{
+			id:     "GALE",
+			number: 0,
+			sector: 0,
+			seed: "5cffd715e11a8088ff574e2c3d76b515c3dc48a6eff30654cffbfaa28c44cf9e1bd7dc6bd5307c7f" +
+				"c699911c2bfa1ea54adc6a5e944831a61326cbd27b5ad9eb905b5a9d",
+			junk:   "94215ada27f15c2d8bc834abfa884b9d",
+			at1000: "8be254c66423b300e41a8d72c6bf2f23",
+		},
+		{
+			id:     "GALE",
+			number: 1,
+			sector: 5,
+			seed: "694350fa2595f8cae5a861671af35ad1062168f902f75dc1cc36441b9e20954dcb4bfc0473041048" +
+				"62dfea319a16ea790500b8d5a2ffc5d8f21271c7299a54025c301c67",
+			junk:   "c3e4a1bb96a365e8e68016725be56bb9",
+			at1000: "d1578e5f3c303cc59430d652e7deb913",
+		},
+		{
+			id:     "RSPE",
+			number: 0,
+			sector: 0x1234,
+			seed: "54e56b2a3f5c5ab19f933e6f5422ff6ba708b1a9f993482b8e11f91a95eed16fa3177f0b995dd9eb" +
+				"be504b1286fa7272f65ee83a533e2978ca1f8a860ce60a96ca386602",
+			junk:   "4fe05319528068f1a407c07e6d7f8858",
+			at1000: "816af52136886a65ae959eafd42c1805",
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(fmt.Sprintf("%s/%d/%d", table.id, table.number, table.sector), func(t *testing.T) {
+			t.Parallel()
+
+			d := junk.Disc{Number: table.number}
+			copy(d.ID[:], table.id)
+
+			seed := d.Seed(table.sector)
+			assert.Equal(t, table.seed, hex.EncodeToString(seed[:]))
+
+			b := make([]byte, 0x1010)
+			if _, err := io.ReadFull(d.NewReader(table.sector*junk.SectorSize), b); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, table.junk, hex.EncodeToString(b[:0x10]))
+			assert.Equal(t, table.at1000, hex.EncodeToString(b[0x1000:]))
+		})
+	}
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	b := generate(t, 0, 3*junk.SectorSize)
+
+	// Each sector is seeded afresh
+	seed := testDisc.Seed(1)
+
+	rc, err := padding.NewReadCloser(bytes.NewReader(seed[:]), junk.SectorSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	sector := make([]byte, junk.SectorSize)
+	if _, err = io.ReadFull(rc, sector); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, sector, b[junk.SectorSize:2*junk.SectorSize])
+	assert.NotEqual(t, b[:junk.SectorSize], b[junk.SectorSize:2*junk.SectorSize])
+
+	// Starting part way through gives the same data
+	assert.Equal(t, b[junk.SectorSize+0x1234:], generate(t, junk.SectorSize+0x1234, 2*junk.SectorSize-0x1234))
+
+	// As do small reads across the sector boundaries
+	r := testDisc.NewReader(0)
+	small := make([]byte, 0, len(b))
+
+	for len(small) < len(b) {
+		p := make([]byte, 1000)
+		n, err := r.Read(p)
+		assert.NoError(t, err)
+
+		small = append(small, p[:n]...)
+	}
+
+	assert.Equal(t, b, small[:len(b)])
+}
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	const offset = 0x10000
+
+	b := make([]byte, 4*junk.SectorSize)
+	rand.New(rand.NewSource(1)).Read(b) //nolint:gosec
+
+	// Junk from the middle of the second sector to the middle of the
+	// fourth, and a short run that shouldn't count
+	copy(b[0xc000:0x1c000], generate(t, offset+0xc000, 0x10000))
+	copy(b[0x1d000:0x1d010], generate(t, offset+0x1d000, 0x10))
+
+	tables := map[string]struct {
+		offset int64
+		b      []byte
+		ranges []junk.Range
+	}{
+		"all": {
+			offset: offset,
+			b:      b,
+			ranges: []junk.Range{{Offset: offset + 0xc000, Size: 0x10000}},
+		},
+		"unaligned": {
+			offset: offset + 0x1000,
+			b:      b[0x1000:0x14000],
+			ranges: []junk.Range{{Offset: offset + 0xc000, Size: 0x8000}},
+		},
+		"none": {
+			offset: offset,
+			b:      b[:0xc000],
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := junk.Scan(bytes.NewReader(table.b), testDisc, table.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, table.ranges, m.Ranges)
+			assert.Equal(t, int64(len(table.b)), m.Size)
+			assert.InDelta(t, float64(m.Junk())*100/float64(len(table.b)), m.Percent(), 0.001)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package junk
+
+import (
+	"errors"
+	"io"
+
+	"github.com/bodgit/rvz/internal/padding"
+)
+
+// minRun is the shortest run of bytes that is counted as junk. Shorter runs
+// can match the junk data by chance.
+const minRun = padding.SeedSize
+
+// A Range is a run of junk data in a disc image.
+type Range struct {
+	Offset, Size int64
+}
+
+// End returns the offset just past the end of the range.
+func (r Range) End() int64 {
+	return r.Offset + r.Size
+}
+
+// A Map records where the junk data is in a disc image.
+type Map struct {
+	// Ranges holds each run of junk data in order.
+	Ranges []Range
+	// Size is how many bytes were scanned.
+	Size int64
+}
+
+// Junk returns how many bytes of junk data were found.
+func (m *Map) Junk() (n int64) {
+	for _, r := range m.Ranges {
+		n += r.Size
+	}
+
+	return
+}
+
+// Percent returns how much of the scanned data is junk, as a percentage.
+func (m *Map) Percent() float64 {
+	if m.Size == 0 {
+		return 0
+	}
+
+	return float64(m.Junk()) * 100 / float64(m.Size)
+}
+
+func (m *Map) add(offset, size int64) {
+	if size < minRun {
+		return
+	}
+
+	if n := len(m.Ranges); n > 0 && m.Ranges[n-1].End() == offset {
+		m.Ranges[n-1].Size += size
+
+		return
+	}
+
+	m.Ranges = append(m.Ranges, Range{Offset: offset, Size: size})
+}
+
+// scan compares b with the junk data in junk, which both start at offset,
+// and records each run that matches. It returns the length of any run still
+// going at the end of b, which can carry on into the next sector.
+func (m *Map) scan(b, junk []byte, offset, run int64) int64 {
+	for i := range b {
+		if b[i] == junk[i] {
+			run++
+
+			continue
+		}
+
+		m.add(offset+int64(i)-run, run)
+		run = 0
+	}
+
+	return run
+}
+
+// Scan reads the disc image from r until io.EOF, starting at offset in the
+// image, and returns a Map of the junk data for disc d that it holds. It
+// only finds junk data that isn't encrypted, so the partitions of a Wii disc
+// have to be scanned separately from their decrypted data, with offsets
+// counting from the start of that data.
+func Scan(r io.Reader, d Disc, offset int64) (*Map, error) {
+	var (
+		m    = &Map{}
+		jr   = d.NewReader(offset)
+		b    = make([]byte, SectorSize)
+		junk = make([]byte, SectorSize)
+		run  int64
+	)
+
+	for {
+		// Read up to the next sector boundary
+		n, err := io.ReadFull(r, b[:SectorSize-offset%SectorSize])
+		if n > 0 {
+			_, _ = jr.Read(junk[:n])
+			run = m.scan(b[:n], junk[:n], offset, run)
+			offset += int64(n)
+			m.Size += int64(n)
+		}
+
+		if err != nil {
+			m.add(offset-run, run)
+
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return m, nil
+			}
+
+			return m, err
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package rvz
+
+import (
+	"sort"
+
+	"github.com/bodgit/rvz/internal/util"
+)
+
+// RegionKind describes what a Region of the disc image holds.
+type RegionKind int
+
+// The kinds of Region.
+const (
+	RawData         RegionKind = iota // unpartitioned data
+	PartitionHeader                   // the ticket, TMD, certificates and H3 table of a Wii partition
+	PartitionData                     // the encrypted data of a Wii partition
+)
+
+func (k RegionKind) String() string {
+	switch k {
+	case RawData:
+		return "raw data"
+	case PartitionHeader:
+		return "partition header"
+	case PartitionData:
+		return "partition data"
+	default:
+		return "unknown"
+	}
+}
+
+// A Region is a contiguous part of the disc image, as returned by Layout.
+type Region struct {
+	Kind RegionKind
+	// Offset and End are where the region starts and ends in the disc
+	// image, End being exclusive.
+	Offset, End int64
+	// Partition is the index of the partition entry in the image for
+	// PartitionHeader and PartitionData regions, or -1.
+	Partition int
+	// FirstGroup and NumGroup are the groups holding the region. A group
+	// can be shared with a neighbouring region.
+	FirstGroup, NumGroup int
+	// CompressedSize and StoredSize are how many bytes of the image are
+	// used by groups that are compressed and by groups stored as-is. A
+	// group is only counted in the region it starts in.
+	CompressedSize, StoredSize int64
+}
+
+// Size returns the size of the region in the disc image.
+func (rg Region) Size() int64 {
+	return rg.End - rg.Offset
+}
+
+// partHeader is where a partition header lies in the disc image.
+type partHeader struct {
+	offset, end int64
+	part        int
+}
+
+// headerPart returns the partition entry whose data follows the partition
+// header at offset, or -1, along with where the header ends.
+func (r *Reader) headerPart(offset int64) (int, int64) {
+	p, end := -1, int64(r.header.IsoFileSize)
+
+	for i, x := range r.part {
+		if start := int64(x.Data[0].FirstSector) * util.SectorSize; start > offset && start <= end {
+			p, end = i, start
+		}
+	}
+
+	return p, end
+}
+
+// splitRaw splits the raw data region rg into any partition headers it
+// contains and the data between them.
+func (r *Reader) splitRaw(rg region, headers []partHeader) []Region {
+	var (
+		regions []Region
+		offset  = rg.offset
+		end     = rg.offset + rg.size
+	)
+
+	add := func(kind RegionKind, start, stop int64, p int) {
+		if stop > end {
+			stop = end
+		}
+
+		if stop <= start {
+			return
+		}
+
+		regions = append(regions, Region{Kind: kind, Offset: start, End: stop, Partition: p})
+	}
+
+	for _, h := range headers {
+		if h.end <= offset || h.offset >= end {
+			continue
+		}
+
+		if h.offset > offset {
+			add(RawData, offset, h.offset, -1)
+			offset = h.offset
+		}
+
+		add(PartitionHeader, offset, h.end, h.part)
+		offset = h.end
+	}
+
+	add(RawData, offset, end, -1)
+
+	return regions
+}
+
+// ownGroups calls fn for each group of rg that starts within region, along
+// with where it starts in the disc image.
+func (r *Reader) ownGroups(rg region, region Region, fn func(g int, start int64)) {
+	chunkSize := r.disc.chunkSize(false)
+
+	for g := region.FirstGroup; g < region.FirstGroup+region.NumGroup && g < len(r.group); g++ {
+		if start := rg.offset + int64(g-int(rg.groupIndex))*chunkSize; start >= region.Offset {
+			fn(g, start)
+		}
+	}
+}
+
+// sizeGroups fills in the groups and sizes of each of the regions carved
+// from rg.
+func (r *Reader) sizeGroups(rg region, regions []Region) {
+	chunkSize := r.disc.chunkSize(false)
+
+	for i := range regions {
+		first := (regions[i].Offset - rg.offset) / chunkSize
+		last := (regions[i].End - rg.offset - 1) / chunkSize
+
+		regions[i].FirstGroup = int(rg.groupIndex) + int(first)
+		regions[i].NumGroup = int(last-first) + 1
+
+		// Count each group once, in the region it starts in
+		r.ownGroups(rg, regions[i], func(g int, _ int64) {
+			if grp := &r.group[g]; grp.compressed() {
+				regions[i].CompressedSize += grp.size()
+			} else {
+				regions[i].StoredSize += grp.size()
+			}
+		})
+	}
+}
+
+// layout returns the regions making up the disc image in order, along with
+// the raw data or partition data region each was carved from.
+func (r *Reader) layout() ([]Region, []region, error) {
+	entries, err := r.partitionEntries()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers := make([]partHeader, 0, len(entries))
+
+	for _, e := range entries {
+		offset := int64(e.Offset) << 2
+		if p, end := r.headerPart(offset); p >= 0 {
+			headers = append(headers, partHeader{offset, end, p})
+		}
+	}
+
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].offset < headers[j].offset
+	})
+
+	var (
+		layout  []Region
+		parents []region
+	)
+
+	for _, rg := range r.regions() {
+		var regions []Region
+
+		if rg.raw < 0 {
+			regions = []Region{{
+				Kind:      PartitionData,
+				Offset:    rg.offset,
+				End:       rg.offset + rg.size,
+				Partition: rg.part,
+			}}
+		} else {
+			regions = r.splitRaw(rg, headers)
+		}
+
+		r.sizeGroups(rg, regions)
+		layout = append(layout, regions...)
+
+		for range regions {
+			parents = append(parents, rg)
+		}
+	}
+
+	return layout, parents, nil
+}
+
+// Layout returns the regions making up the disc image in order. For a Wii
+// disc the partition table is read to find the partition headers.
+func (r *Reader) Layout() ([]Region, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+
+	layout, _, err := r.layout()
+
+	return layout, err
+}
@@ -0,0 +1,91 @@
+package rvz_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+//nolint:funlen
+func TestLayout(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+	dataStart := int64(tp.firstSector) * sectorSize
+
+	tables := []struct {
+		name  string
+		ti    *testImage
+		kinds []rvz.RegionKind
+		ends  []int64
+	}{
+		{
+			name:  "GameCube",
+			ti:    &testImage{method: methodZstd, iso: testISO(20)},
+			kinds: []rvz.RegionKind{rvz.RawData},
+			ends:  []int64{20 * sectorSize},
+		},
+		{
+			name: "Wii",
+			ti: &testImage{
+				method:     methodZstd,
+				iso:        iso,
+				partitions: []testPartition{tp},
+			},
+			kinds: []rvz.RegionKind{rvz.RawData, rvz.PartitionHeader, rvz.PartitionData, rvz.RawData},
+			ends:  []int64{testPartOffset, dataStart, dataStart + 140*sectorSize, int64(len(iso))},
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := rvz.NewReader(bytes.NewReader(table.ti.build(t)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			layout, err := r.Layout()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				kinds  []rvz.RegionKind
+				ends   []int64
+				offset int64
+				size   int64
+				group  int
+			)
+
+			for _, rg := range layout {
+				kinds = append(kinds, rg.Kind)
+				ends = append(ends, rg.End)
+
+				assert.Equal(t, offset, rg.Offset)
+				assert.LessOrEqual(t, rg.FirstGroup, group)
+				assert.NotZero(t, rg.NumGroup)
+
+				if rg.Kind == rvz.RawData {
+					assert.Equal(t, -1, rg.Partition)
+				} else {
+					assert.Equal(t, 0, rg.Partition)
+				}
+
+				offset = rg.End
+				size += rg.CompressedSize + rg.StoredSize
+				group = rg.FirstGroup + rg.NumGroup
+			}
+
+			assert.Equal(t, table.kinds, kinds)
+			assert.Equal(t, table.ends, ends)
+			assert.Equal(t, r.Size(), offset)
+			assert.NotZero(t, size)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package rvz
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// HashCheck selects which of the hashes stored in the image are checked when
+// it is opened.
+type HashCheck uint
+
+// The hashes that can be checked.
+const (
+	HeaderHash HashCheck = 1 << iota // the file header
+	DiscHash                         // the disc struct
+	PartHash                         // the partition table
+
+	AllHashes = HeaderHash | DiscHash | PartHash
+)
+
+// An Option configures a Reader.
+type Option func(*Reader) error
+
+// WithConcurrency sets how many goroutines are used to decode and encrypt
+// each cluster of a Wii partition, or by WriteTo to decode that many groups
+// or clusters at once. The default is runtime.NumCPU().
+func WithConcurrency(n int) Option {
+	return func(r *Reader) error {
+		if n < 1 {
+			return fmt.Errorf("%w: concurrency must be at least 1", ErrOption)
+		}
+
+		r.concurrency = n
+
+		return nil
+	}
+}
+
+// WithWorkspacePool shares the workspaces used to rebuild clusters of Wii
+// partitions with any other readers using pool. Each reader otherwise has its
+// own.
+func WithWorkspacePool(pool *WorkspacePool) Option {
+	return func(r *Reader) error {
+		if pool == nil {
+			return fmt.Errorf("%w: nil workspace pool", ErrOption)
+		}
+
+		r.pool = pool
+
+		return nil
+	}
+}
+
+// WithHashChecks selects which hashes are checked when the image is opened,
+// the default being AllHashes. Use zero to skip them all.
+func WithHashChecks(checks HashCheck) Option {
+	return func(r *Reader) error {
+		if checks&^AllHashes != 0 {
+			return fmt.Errorf("%w: unknown hash check %#x", ErrOption, uint(checks&^AllHashes))
+		}
+
+		r.checks = checks
+
+		return nil
+	}
+}
+
+// WithDecompressor uses dcomp for the specified method in this reader only,
+// taking precedence over any registered Decompressor.
+func WithDecompressor(method uint32, dcomp Decompressor) Option {
+	return func(r *Reader) error {
+		if dcomp == nil {
+			return fmt.Errorf("%w: nil decompressor", ErrOption)
+		}
+
+		if r.decompressors == nil {
+			r.decompressors = make(map[uint32]Decompressor)
+		}
+
+		r.decompressors[method] = dcomp
+
+		return nil
+	}
+}
+
+func (r *Reader) setOptions(opts []Option) error {
+	r.concurrency = runtime.NumCPU()
+	r.checks = AllHashes
+
+	for _, o := range opts {
+		if err := o(r); err != nil {
+			return err
+		}
+	}
+
+	if r.pool == nil {
+		r.pool = NewWorkspacePool(0)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package rvz_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+//nolint:funlen
+func TestOptions(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+	b := (&testImage{method: methodZstd, iso: iso, partitions: []testPartition{tp}}).build(t)
+
+	// Two readers sharing a pool that only allows one cluster at a time
+	pool := rvz.NewWorkspacePool(1)
+
+	for i := 0; i < 2; i++ {
+		t.Run(fmt.Sprintf("Pool%d", i), func(t *testing.T) {
+			t.Parallel()
+
+			r, err := rvz.NewReader(bytes.NewReader(b), rvz.WithWorkspacePool(pool), rvz.WithConcurrency(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.True(t, bytes.Equal(iso, out))
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		t.Parallel()
+
+		for _, opt := range []rvz.Option{
+			rvz.WithConcurrency(0),
+			rvz.WithWorkspacePool(nil),
+			rvz.WithHashChecks(rvz.AllHashes + 1),
+			rvz.WithDecompressor(methodZstd, nil),
+		} {
+			_, err := rvz.NewReader(bytes.NewReader(b), opt)
+			assert.ErrorIs(t, err, rvz.ErrOption)
+		}
+	})
+
+	t.Run("HashChecks", func(t *testing.T) {
+		t.Parallel()
+
+		b := (&testImage{iso: testISO(4)}).build(t)
+		b[0x34] ^= 0xff // the header hash itself
+
+		_, err := rvz.NewReader(bytes.NewReader(b), rvz.WithHashChecks(rvz.DiscHash|rvz.PartHash))
+		assert.NoError(t, err)
+
+		_, err = rvz.NewReader(bytes.NewReader(b), rvz.WithHashChecks(rvz.HeaderHash))
+		assert.ErrorIs(t, err, rvz.ErrHeaderHash)
+	})
+
+	t.Run("Decompressor", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int
+
+		dcomp := func(_ []byte, r io.Reader) (io.ReadCloser, error) {
+			calls++
+
+			return io.NopCloser(r), nil
+		}
+
+		b := (&testImage{iso: testISO(4)}).build(t)
+
+		r, err := rvz.NewReader(bytes.NewReader(b), rvz.WithDecompressor(methodNone, dcomp))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = io.Copy(io.Discard, r)
+		assert.NoError(t, err)
+		assert.NotZero(t, calls)
+	})
+}
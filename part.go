@@ -2,35 +2,22 @@ package rvz
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/sha1" //nolint:gosec
 	"io"
-	"runtime"
+	"sync"
 
-	"github.com/bodgit/plumbing"
 	"github.com/bodgit/rvz/internal/util"
+	"github.com/bodgit/rvz/wii"
 	"golang.org/x/sync/errgroup"
 )
 
 const (
-	subGroup         = 8
-	clusters         = subGroup * subGroup // 8 groups of 8 subgroups
-	blocksPerCluster = 31
-
-	h0Size    = blocksPerCluster * sha1.Size
-	h0Padding = 0x14
-	h1Size    = subGroup * sha1.Size
-	h1Padding = 0x20
-	h2Size    = h1Size
-	h2Padding = h1Padding
-	hashSize  = h0Size + h0Padding + h1Size + h1Padding + h2Size + h2Padding
-
-	blockSize = (util.SectorSize - hashSize) / blocksPerCluster
-
-	ivOffset = 0x03d0
-
-	groupSize = util.SectorSize * clusters // 2 MiB
+	clusters       = wii.SectorsPerCluster
+	hashSize       = wii.HashBlockSize
+	sectorDataSize = wii.SectorDataSize
+	groupSize      = wii.ClusterSize // 2 MiB
 )
 
 func min(x, y int) int {
@@ -42,169 +29,160 @@ func min(x, y int) int {
 }
 
 type partReader struct {
-	h0 [clusters]*bytes.Buffer
-	h1 [subGroup]io.Writer
-	h2 io.Writer
-
-	cluster [clusters]*bytes.Buffer
+	block cipher.Block
 
 	buf []byte
 	br  *bytes.Reader
 
 	p, d   int
-	r      *reader
+	r      *Reader
 	sector int
+	quiet  bool // don't report progress
+	serial bool // decode and encrypt the cluster in one goroutine
 }
 
-func (pr *partReader) groupOffset(g int) int64 {
-	return (int64(g) - int64(pr.r.part[pr.p].Data[pr.d].GroupIndex)) * pr.r.disc.chunkSize(true)
+// workers returns how many goroutines are used for each cluster.
+func (pr *partReader) workers() int {
+	if pr.serial {
+		return 1
+	}
+
+	return pr.r.concurrency
 }
 
-func (pr *partReader) reset() {
-	for i := 0; i < clusters; i++ {
-		pr.h0[i].Reset()
-		pr.cluster[i].Reset()
-	}
+func (pr *partReader) groupOffset(g int) int64 {
+	return (int64(g) - int64(pr.r.part[pr.p].Data[pr.d].GroupIndex)) * pr.r.disc.chunkSize(true)
 }
 
-func (pr *partReader) readGroup(i int) error {
+func (pr *partReader) readGroup(ws *workspace, i int) error {
 	ss := i * pr.r.disc.sectorsPerChunk()
 	g := pr.sectorToGroup(pr.sector + ss)
 
-	h := sha1.New() //nolint:gosec
+	if err := pr.decodeGroup(ws, g, ss); err != nil {
+		offset := (int64(pr.r.part[pr.p].Data[pr.d].FirstSector) + int64(pr.sector+ss)) * util.SectorSize
+
+		return pr.r.decodeError(err, offset, g, pr.p)
+	}
+
+	return nil
+}
+
+// decodeGroup decodes group g straight into the workspace, starting at
+// sector ss of the cluster, and calculates the H0 hashes of each sector.
+func (pr *partReader) decodeGroup(ws *workspace, g, ss int) error {
+	spc := pr.r.disc.sectorsPerChunk()
 
-	split := min(ss+pr.r.disc.sectorsPerChunk(), int(pr.r.part[pr.p].Data[pr.d].NumSector)-pr.sector)
+	split := min(ss+spc, int(pr.r.part[pr.p].Data[pr.d].NumSector)-pr.sector)
 	if split < ss {
 		split = ss
 	}
 
-	var (
-		rc  io.ReadCloser
-		err error
-		zr  = plumbing.DevZero()
-		r   io.Reader
-	)
-
 	if split > ss {
-		rc, _, err = pr.r.groupReader(g, pr.groupOffset(g), true)
+		rc, _, err := pr.r.groupReader(g, pr.groupOffset(g), true)
 		if err != nil {
 			return err
 		}
 		defer rc.Close()
-	}
-
-	for j := ss; j < ss+pr.r.disc.sectorsPerChunk(); j++ {
-		if j < split {
-			r = rc
-		} else {
-			r = zr
-		}
 
-		for k := 0; k < blocksPerCluster; k++ {
-			h.Reset()
-
-			if _, err := io.CopyN(pr.cluster[j], io.TeeReader(r, h), blockSize); err != nil {
+		for j := ss; j < split; j++ {
+			if _, err = io.ReadFull(rc, ws.Data[j][:]); err != nil {
 				return err
 			}
-
-			_, _ = pr.h0[j].Write(h.Sum(nil))
 		}
-
-		_, _ = io.CopyN(pr.h0[j], plumbing.DevZero(), h0Padding)
 	}
 
-	return nil
-}
-
-func (pr *partReader) writeHashes() {
-	h := sha1.New() //nolint:gosec
-
-	buf := make([]byte, hashSize)
-
-	// Calculate the H1 hashes
-	for i := 0; i < subGroup; i++ {
-		for j := 0; j < subGroup; j++ {
-			h.Reset()
-			_, _ = io.CopyBuffer(h, io.LimitReader(bytes.NewReader(pr.h0[i*subGroup+j].Bytes()), h0Size), buf)
-			_, _ = pr.h1[i].Write(h.Sum(nil))
-		}
-
-		_, _ = io.CopyN(pr.h1[i], plumbing.DevZero(), h1Padding)
+	// Anything past the end of the partition is zeroes
+	for j := split; j < ss+spc; j++ {
+		ws.Data[j] = [sectorDataSize]byte{}
 	}
 
-	// Calculate the H2 hashes
-	for i := 0; i < subGroup; i++ {
-		h.Reset()
-		_, _ = io.CopyBuffer(h,
-			io.NewSectionReader(bytes.NewReader(pr.h0[i*subGroup].Bytes()), h0Size+h0Padding, h1Size),
-			buf)
-		_, _ = pr.h2.Write(h.Sum(nil))
+	for j := ss; j < ss+spc; j++ {
+		ws.HashSector(j)
 	}
 
-	_, _ = io.CopyN(pr.h2, plumbing.DevZero(), h2Padding)
+	return nil
 }
 
-//nolint:gochecknoglobals
-var iv = make([]byte, aes.BlockSize) // 16 x 0x00
-
-func (pr *partReader) encryptSector(sector int) error {
-	block, err := aes.NewCipher(pr.r.part[pr.p].Key[:])
-	if err != nil {
-		return err
-	}
-
-	offset := sector * util.SectorSize
-
-	e := cipher.NewCBCEncrypter(block, iv)
-	e.CryptBlocks(pr.buf[offset:], pr.h0[sector].Bytes())
-
-	e = cipher.NewCBCEncrypter(block, pr.buf[offset+ivOffset:offset+ivOffset+aes.BlockSize])
-	e.CryptBlocks(pr.buf[offset+hashSize:], pr.cluster[sector].Bytes())
+// encryptSector encrypts sector of the cluster held in ws into out.
+func (pr *partReader) encryptSector(ws *workspace, sector int, out []byte) {
+	ws.EncryptSector(pr.block, sector, out)
+}
 
-	return nil
+// offset returns the offset in the disc image of the current sector.
+func (pr *partReader) offset() int64 {
+	return (int64(pr.r.part[pr.p].Data[pr.d].FirstSector) + int64(pr.sector)) * util.SectorSize
 }
 
 func (pr *partReader) sectorToGroup(sector int) int {
 	return int(pr.r.part[pr.p].Data[pr.d].GroupIndex) + sector/(int(pr.r.disc.ChunkSize)/util.SectorSize)
 }
 
-//nolint:nakedret
-func (pr *partReader) read() (err error) {
-	eg := new(errgroup.Group)
-	eg.SetLimit(runtime.NumCPU())
+// build decodes the groups making up the current cluster and calculates
+// the hashes for it, without encrypting anything. The workspace holding the
+// result must be returned to the pool afterwards.
+func (pr *partReader) build(ctx context.Context) (*workspace, error) {
+	ws, err := pr.r.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	pr.reset()
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(pr.workers())
 
 	for i := 0; i < groupSize/int(pr.r.disc.ChunkSize); i++ {
 		i := i
 
 		eg.Go(func() error {
-			return pr.readGroup(i)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			return pr.readGroup(ws, i)
 		})
 	}
 
-	if err = eg.Wait(); err != nil {
-		return
+	if err := eg.Wait(); err != nil {
+		pr.r.pool.put(ws)
+
+		return nil, err
 	}
 
-	pr.writeHashes()
+	ws.HashTree()
+
+	return ws, nil
+}
+
+func (pr *partReader) read(ctx context.Context) error {
+	ws, err := pr.build(ctx)
+	if err != nil {
+		return err
+	}
+	defer pr.r.pool.put(ws)
 
 	sectors := min(clusters, int(pr.r.part[pr.p].Data[pr.d].NumSector)-pr.sector)
 
 	pr.buf = pr.buf[:(sectors * util.SectorSize)]
 
-	for i := 0; i < sectors; i++ {
-		i := i
+	// Split the sectors evenly between the workers
+	var (
+		wg   sync.WaitGroup
+		step = (sectors + pr.workers() - 1) / pr.workers()
+	)
 
-		eg.Go(func() error {
-			return pr.encryptSector(i)
-		})
-	}
+	for i := 0; i < sectors; i += step {
+		wg.Add(1)
+
+		go func(start, end int) {
+			defer wg.Done()
 
-	if err = eg.Wait(); err != nil {
-		return
+			for j := start; j < end; j++ {
+				pr.encryptSector(ws, j, pr.buf[j*util.SectorSize:(j+1)*util.SectorSize])
+			}
+		}(i, min(i+step, sectors))
 	}
 
+	wg.Wait()
+
 	return nil
 }
 
@@ -214,13 +192,20 @@ func (pr *partReader) Read(p []byte) (n int, err error) {
 			return 0, io.EOF
 		}
 
-		if err = pr.read(); err != nil {
+		if pr.buf == nil {
+			pr.buf = make([]byte, 0, groupSize) // 2 MiB
+		}
+
+		if err = pr.read(pr.r.ctx); err != nil {
 			return
 		}
 
 		pr.br.Reset(pr.buf)
 
 		pr.sector += pr.br.Len() / util.SectorSize
+		if !pr.quiet {
+			pr.r.report(pr.offset(), pr.p, pr.sectorToGroup(pr.sector-1))
+		}
 	}
 
 	n, err = pr.br.Read(p)
@@ -228,40 +213,69 @@ func (pr *partReader) Read(p []byte) (n int, err error) {
 	return
 }
 
-func newPartReader(r *reader, p, d int) io.Reader {
-	pr := &partReader{
-		p: p,
-		d: d,
-		r: r,
-	}
+// A clusterCache holds the last cluster decoded by ReadAt so that small
+// reads from the same cluster don't rebuild it each time.
+type clusterCache struct {
+	mu     sync.Mutex
+	pr     *partReader
+	sector int // first sector of the cluster held in pr.buf
+}
 
-	pr.buf = make([]byte, 0, groupSize) // 2 MiB
-	pr.br = bytes.NewReader(pr.buf)
+// readAt copies the encrypted cluster of partition entry p, data entry d
+// holding skip bytes into the data into b, returning how much was copied.
+func (cc *clusterCache) readAt(r *Reader, p, d int, b []byte, skip int64) (int, error) {
+	sector := int(skip/groupSize) * clusters
+	skip %= groupSize
 
-	h1 := make([][]io.Writer, subGroup)
-	for i := range h1 {
-		h1[i] = make([]io.Writer, 0, subGroup)
+	cc.mu.Lock()
+	pr := cc.pr
+
+	if pr != nil && pr.p == p && pr.d == d && cc.sector == sector {
+		n := copy(b, pr.buf[skip:])
+		cc.mu.Unlock()
+
+		return n, nil
 	}
 
-	for i := range pr.h0 {
-		pr.h0[i] = new(bytes.Buffer)
-		pr.h0[i].Grow(hashSize) // 0x400
+	// Decode without holding the lock so other calls can still be served
+	cc.pr = nil
+	cc.mu.Unlock()
 
-		j := i / subGroup
+	if pr == nil || pr.p != p || pr.d != d {
+		pr = newPartReader(r, p, d)
+	}
 
-		h1[j] = append(h1[j], pr.h0[i])
+	if pr.buf == nil {
+		pr.buf = make([]byte, 0, groupSize) // 2 MiB
 	}
 
-	for i := range pr.cluster {
-		pr.cluster[i] = new(bytes.Buffer)
-		pr.cluster[i].Grow(util.SectorSize - hashSize) // 0x7c00
+	pr.sector = sector
+
+	if err := pr.read(r.ctx); err != nil {
+		return 0, err
 	}
 
-	for i := range pr.h1 {
-		pr.h1[i] = io.MultiWriter(h1[i]...)
+	n := copy(b, pr.buf[skip:])
+
+	cc.mu.Lock()
+	cc.pr, cc.sector = pr, sector
+	cc.mu.Unlock()
+
+	return n, nil
+}
+
+func newPartReader(r *Reader, p, d int) *partReader {
+	// The key is always the right size, so this can't fail
+	block, _ := aes.NewCipher(r.part[p].Key[:])
+
+	pr := &partReader{
+		block: block,
+		p:     p,
+		d:     d,
+		r:     r,
 	}
 
-	pr.h2 = io.MultiWriter(pr.h1[:]...)
+	pr.br = bytes.NewReader(nil)
 
 	return pr
 }
@@ -0,0 +1,125 @@
+package rvz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bodgit/rvz/wii"
+)
+
+const (
+	partitionTableOffset = 0x40000
+	maxPartitions        = 0x40 // per table
+)
+
+type partitionTable struct {
+	Count  uint32
+	Offset uint32 // >> 2
+}
+
+type partitionEntry struct {
+	Offset uint32 // >> 2
+	Type   uint32
+}
+
+// Partition describes a Wii partition listed in the partition tables.
+type Partition struct {
+	// Offset is the offset of the partition in the disc image.
+	Offset int64
+	// Type is 0 for game data, 1 for a system update and 2 for a channel
+	// installer.
+	Type uint32
+	// Header holds the ticket, TMD and certificate chain.
+	Header *wii.PartitionHeader
+}
+
+// discReaderAt reads the uncompressed disc image, remembering any error
+// that isn't simply reading past the end.
+type discReaderAt struct {
+	r   *Reader
+	err error
+}
+
+func (d *discReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	n, err := d.r.readAt(p, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		d.err = err
+	}
+
+	return n, err
+}
+
+// readStruct reads the data structure at offset in the disc image.
+func (r *Reader) readStruct(offset int64, data interface{}) error {
+	b := make([]byte, binary.Size(data))
+	if _, err := r.readAt(b, offset); err != nil {
+		return err
+	}
+
+	return binary.Read(bytes.NewReader(b), binary.BigEndian, data)
+}
+
+// Partitions returns every partition listed in the Wii partition tables
+// along with its parsed header. A GameCube disc image has no partitions.
+func (r *Reader) Partitions() ([]Partition, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := r.partitionEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	var partitions []Partition
+
+	for _, e := range entries {
+		offset := int64(e.Offset) << 2
+		d := &discReaderAt{r: r}
+
+		ph, err := wii.ReadPartitionHeader(d, offset)
+
+		switch {
+		case d.err != nil:
+			return nil, d.err
+		case err != nil:
+			return nil, &dataError{fmt.Errorf("partition at %#x: %w", offset, err)}
+		}
+
+		partitions = append(partitions, Partition{Offset: offset, Type: e.Type, Header: ph})
+	}
+
+	return partitions, nil
+}
+
+// partitionEntries returns the entries of all of the Wii partition tables.
+func (r *Reader) partitionEntries() ([]partitionEntry, error) {
+	if r.disc.DiscType != discWii {
+		return nil, nil
+	}
+
+	var tables [4]partitionTable
+	if err := r.readStruct(partitionTableOffset, &tables); err != nil {
+		return nil, err
+	}
+
+	var entries []partitionEntry
+
+	for _, t := range tables {
+		if t.Count > maxPartitions {
+			return nil, ErrPartitionTable
+		}
+
+		e := make([]partitionEntry, t.Count)
+		if err := r.readStruct(int64(t.Offset)<<2, e); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e...)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,67 @@
+package rvz_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/bodgit/rvz/wii"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitions(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 70)
+
+	r, err := rvz.NewReader(bytes.NewReader((&testImage{iso: iso, partitions: []testPartition{tp}}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partitions, err := r.Partitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, partitions, 1) {
+		return
+	}
+
+	p := partitions[0]
+	assert.Equal(t, int64(testPartOffset), p.Offset)
+	assert.Equal(t, uint32(0), p.Type)
+	assert.Equal(t, int64(testDataOffset), p.Header.DataOffset)
+	assert.Equal(t, "00010000-RMCE", p.Header.Ticket.TitleID.String())
+	assert.Equal(t, p.Header.Ticket.TitleID, p.Header.TMD.TitleID)
+	assert.Equal(t, uint32(36), p.Header.TMD.IOSVersion())
+	assert.Len(t, p.Header.TMD.Contents, 1)
+	assert.Empty(t, p.Header.Certificates)
+
+	// The test partition is signed using the trucha bug
+	assert.ErrorIs(t, p.Header.Ticket.Verify(p.Header.Certificates, nil), wii.ErrFakesigned)
+	assert.ErrorIs(t, p.Header.TMD.Verify(p.Header.Certificates, nil), wii.ErrFakesigned)
+
+	// Break the ticket
+	iso = append([]byte(nil), iso...)
+	iso[testPartOffset] = 0xff
+
+	r, err = rvz.NewReader(bytes.NewReader((&testImage{iso: iso, partitions: []testPartition{tp}}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.Partitions()
+	assert.ErrorIs(t, err, wii.ErrSignatureType)
+	assert.ErrorIs(t, err, rvz.ErrCorrupt)
+
+	// A GameCube disc has no partitions
+	r, err = rvz.NewReader(bytes.NewReader((&testImage{iso: testISO(8)}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partitions, err = r.Partitions()
+	assert.NoError(t, err)
+	assert.Empty(t, partitions)
+}
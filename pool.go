@@ -0,0 +1,77 @@
+package rvz
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bodgit/rvz/internal/util"
+	"github.com/bodgit/rvz/wii"
+	"golang.org/x/sync/semaphore"
+)
+
+// workspace holds the hash blocks and decoded data of a cluster while it is
+// rebuilt.
+type workspace = wii.Cluster
+
+// workspaceSize is how much memory a workspace uses.
+const workspaceSize = clusters * util.SectorSize // 2 MiB
+
+// A WorkspacePool holds the workspaces that clusters of Wii partitions are
+// rebuilt in so that they can be reused, optionally limiting how many are in
+// use at once. It is safe to share a WorkspacePool between readers.
+type WorkspacePool struct {
+	pool   sync.Pool
+	sem    *semaphore.Weighted
+	weight int64
+}
+
+// NewWorkspacePool returns a new WorkspacePool that lets at most limit bytes
+// of workspaces be in use at once, with decoding waiting for a workspace to
+// be returned once the limit is reached. Each cluster being rebuilt needs
+// about 2 MiB, and a limit of zero means there is no limit.
+//
+// Only the workspaces are counted, not all of the memory used by a reader.
+// Each reader also keeps the encrypted cluster it is reading from, and
+// WriteTo another for each cluster or chunk waiting to be written, which are
+// 2 MiB each and aren't bounded by the limit.
+func NewWorkspacePool(limit int64) *WorkspacePool {
+	bp := &WorkspacePool{
+		pool: sync.Pool{
+			New: func() interface{} {
+				return new(workspace)
+			},
+		},
+	}
+
+	if limit > 0 {
+		// Always allow at least one cluster to be rebuilt
+		bp.weight = workspaceSize
+		if limit < bp.weight {
+			bp.weight = limit
+		}
+
+		bp.sem = semaphore.NewWeighted(limit)
+	}
+
+	return bp
+}
+
+func (bp *WorkspacePool) get(ctx context.Context) (*workspace, error) {
+	if bp.sem != nil {
+		if err := bp.sem.Acquire(ctx, bp.weight); err != nil {
+			return nil, err
+		}
+	}
+
+	ws, _ := bp.pool.Get().(*workspace)
+
+	return ws, nil
+}
+
+func (bp *WorkspacePool) put(ws *workspace) {
+	bp.pool.Put(ws)
+
+	if bp.sem != nil {
+		bp.sem.Release(bp.weight)
+	}
+}
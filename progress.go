@@ -0,0 +1,45 @@
+package rvz
+
+import "fmt"
+
+// Progress reports how far decoding of the disc image has got.
+type Progress struct {
+	// Offset is how many bytes of the disc image have been decoded.
+	Offset int64
+	// Size is the size of the disc image.
+	Size int64
+	// Partition is the Wii partition being decoded, or -1 for raw data.
+	Partition int
+	// Group is the index of the last group decoded.
+	Group int
+}
+
+// WithProgress calls fn each time a group, or a cluster of a Wii partition,
+// has been decoded by Read or WriteTo. It is called from the goroutine
+// calling those methods and must not call back into the Reader. ReadAt and
+// the other methods that decode parts of the disc image don't report
+// progress, so fn is never called concurrently.
+func WithProgress(fn func(Progress)) Option {
+	return func(r *Reader) error {
+		if fn == nil {
+			return fmt.Errorf("%w: nil progress function", ErrOption)
+		}
+
+		r.progress = fn
+
+		return nil
+	}
+}
+
+func (r *Reader) report(offset int64, p, g int) {
+	if r.progress == nil {
+		return
+	}
+
+	r.progress(Progress{
+		Offset:    offset,
+		Size:      int64(r.header.IsoFileSize),
+		Partition: p,
+		Group:     g,
+	})
+}
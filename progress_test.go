@@ -0,0 +1,77 @@
+package rvz_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+
+	b := (&testImage{
+		method:     methodZstd,
+		chunkSize:  sectorSize,
+		iso:        iso,
+		partitions: []testPartition{tp},
+	}).build(t)
+
+	for _, writeTo := range []bool{false, true} {
+		writeTo := writeTo
+
+		t.Run(fmt.Sprintf("WriteTo%v", writeTo), func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				reports    []rvz.Progress
+				partitions = map[int]bool{}
+			)
+
+			r, err := rvz.NewReader(bytes.NewReader(b), rvz.WithProgress(func(p rvz.Progress) {
+				reports = append(reports, p)
+				partitions[p.Partition] = true
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if writeTo {
+				_, err = r.WriteTo(io.Discard)
+			} else {
+				_, err = io.ReadAll(r)
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if assert.NotEmpty(t, reports) {
+				for i := 1; i < len(reports); i++ {
+					assert.Greater(t, reports[i].Offset, reports[i-1].Offset)
+					assert.GreaterOrEqual(t, reports[i].Group, reports[i-1].Group)
+				}
+
+				last := reports[len(reports)-1]
+				assert.Equal(t, int64(len(iso)), last.Offset)
+				assert.Equal(t, int64(len(iso)), last.Size)
+			}
+
+			assert.Equal(t, map[int]bool{-1: true, 0: true}, partitions)
+
+			// ReadAt doesn't report anything
+			n := len(reports)
+			_, err = r.ReadAt(make([]byte, len(iso)), 0)
+			assert.NoError(t, err)
+			assert.Len(t, reports, n)
+		})
+	}
+
+	_, err := rvz.NewReader(bytes.NewReader(b), rvz.WithProgress(nil))
+	assert.ErrorIs(t, err, rvz.ErrOption)
+}
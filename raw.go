@@ -1,15 +1,27 @@
 package rvz
 
 import (
-	"errors"
 	"io"
 )
 
 type rawReader struct {
 	i, g   int
-	r      *reader
+	r      *Reader
 	gr     io.ReadCloser
 	offset int64
+	quiet  bool // don't report progress
+}
+
+// groupEnd returns the offset where the current group's data should end.
+func (rr *rawReader) groupEnd() int64 {
+	x := rr.r.raw[rr.i]
+
+	end := int64(x.RawDataOff) + int64(rr.g-int(x.GroupIndex)+1)*rr.r.disc.chunkSize(false)
+	if end > int64(x.RawDataOff+x.RawDataSize) {
+		end = int64(x.RawDataOff + x.RawDataSize)
+	}
+
+	return end
 }
 
 func (rr *rawReader) Read(p []byte) (n int, err error) {
@@ -19,31 +31,55 @@ func (rr *rawReader) Read(p []byte) (n int, err error) {
 
 	if rr.gr == nil {
 		if rr.gr, _, err = rr.r.groupReader(rr.g, rr.offset, false); err != nil {
-			return
+			return 0, rr.r.decodeError(err, rr.offset, rr.g, -1)
 		}
 	}
 
+	end := rr.groupEnd()
+	if remaining := end - rr.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
 	n, err = rr.gr.Read(p)
 	rr.offset += int64(n)
 
-	if err != nil {
-		if !errors.Is(err, io.EOF) {
+	if rr.offset == end {
+		if err = rr.gr.Close(); err != nil {
 			return
 		}
 
-		if err = rr.gr.Close(); err != nil {
-			return
+		if !rr.quiet {
+			rr.r.report(end, -1, rr.g)
 		}
 
 		rr.g++
 
-		rr.gr, err = nil, nil
+		rr.gr = nil
+
+		return n, nil
+	}
+
+	if err != nil {
+		_ = rr.Close()
+
+		// Running out of data before the end of the group is an error
+		return n, rr.r.decodeError(err, rr.offset, rr.g, -1)
+	}
+
+	return
+}
+
+// Close closes the reader for the current group, if any.
+func (rr *rawReader) Close() (err error) {
+	if rr.gr != nil {
+		err = rr.gr.Close()
+		rr.gr = nil
 	}
 
 	return
 }
 
-func newRawReader(r *reader, i int) io.Reader {
+func newRawReader(r *Reader, i int) *rawReader {
 	return &rawReader{
 		i:      i,
 		g:      int(r.raw[i].GroupIndex),
@@ -2,11 +2,14 @@ package rvz
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/sha1" //nolint:gosec
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"os"
 
 	"github.com/bodgit/plumbing"
 	"github.com/bodgit/rvz/internal/packed"
@@ -21,16 +24,10 @@ const (
 )
 
 const (
-	gameCube = iota + 1
-	wii
+	discGameCube = iota + 1
+	discWii
 )
 
-// A Reader has Read and Size methods.
-type Reader interface {
-	io.Reader
-	Size() int64
-}
-
 //nolint:maligned
 type header struct {
 	Magic             uint32
@@ -68,7 +65,7 @@ type disc struct {
 }
 
 func (d *disc) partReader(ra io.ReaderAt) io.Reader {
-	return io.NewSectionReader(ra, int64(d.PartOff), int64(d.NumPart*d.PartSize))
+	return io.NewSectionReader(ra, int64(d.PartOff), int64(d.NumPart)*int64(d.PartSize))
 }
 
 func (d *disc) rawReader(ra io.ReaderAt) io.Reader {
@@ -117,7 +114,7 @@ type group struct {
 }
 
 func (g *group) offset() int64 {
-	return int64(g.Offset << 2)
+	return int64(g.Offset) << 2
 }
 
 const (
@@ -133,13 +130,26 @@ func (g *group) size() int64 {
 	return int64(g.Size & compressedMask)
 }
 
-type except struct {
+// A HashException replaces one of the hashes of a Wii partition cluster
+// that can't be recalculated from the data, identified by its offset within
+// the hash blocks of the cluster.
+type HashException struct {
 	Offset uint16
 	Hash   [sha1.Size]byte
 }
 
-type reader struct {
-	ra io.ReaderAt
+// A Reader decodes the disc image held in an RVZ file. Besides reading the
+// disc image it gives access to the structure of the file and, for Wii
+// discs, the partitions.
+type Reader struct {
+	ra  io.ReaderAt
+	ctx context.Context //nolint:containedctx // Read can't be passed one
+
+	concurrency   int
+	pool          *WorkspacePool
+	checks        HashCheck
+	decompressors map[uint32]Decompressor
+	progress      func(Progress)
 
 	header header
 	disc   disc
@@ -149,29 +159,104 @@ type reader struct {
 
 	r      io.Reader
 	offset int64
+	at     clusterCache // used by ReadAt
+
+	file   io.Closer // closed with the reader, if set
+	closed bool
+}
+
+// source records any error reading the compressed data so that it can be
+// told apart from the decompressor rejecting the data itself.
+type source struct {
+	r   io.Reader
+	err error
+}
+
+func (s *source) Read(p []byte) (n int, err error) {
+	n, err = s.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.err = err
+	}
+
+	return
+}
+
+// check marks err as caused by bad data unless reading the source failed or
+// the Decompressor doesn't support the data.
+func (s *source) check(err error) error {
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, ErrUnsupported) || s.err != nil {
+		return err
+	}
+
+	return &dataError{err}
+}
+
+type decompressReader struct {
+	io.ReadCloser
+	src *source
 }
 
-func (r *reader) decompressor(reader io.Reader) (io.ReadCloser, error) {
-	dcomp := decompressor(r.disc.Compression)
+func (dr *decompressReader) Read(p []byte) (int, error) {
+	n, err := dr.ReadCloser.Read(p)
+
+	return n, dr.src.check(err)
+}
+
+func (r *Reader) decompressor(reader io.Reader) (io.ReadCloser, error) {
+	dcomp, ok := r.decompressors[r.disc.Compression]
+	if !ok {
+		dcomp = decompressor(r.disc.Compression)
+	}
+
 	if dcomp == nil {
-		return nil, errors.New("rvz: unsupported algorithm")
+		return nil, UnsupportedMethodError(r.disc.Compression)
+	}
+
+	src := &source{r: reader}
+
+	rc, err := dcomp(r.disc.ComprData[0:r.disc.ComprDataLen], src)
+	if err != nil {
+		return nil, src.check(err)
 	}
 
-	return dcomp(r.disc.ComprData[0:r.disc.ComprDataLen], reader)
+	return &decompressReader{rc, src}, nil
 }
 
 //nolint:cyclop,unparam
-func (r *reader) groupReader(g int, offset int64, partition bool) (rc io.ReadCloser, exceptions []except, err error) {
+func (r *Reader) groupReader(g int, offset int64, partition bool) (rc io.ReadCloser, exceptions []HashException, err error) {
+	if rc, err = r.groupData(g, partition); err != nil {
+		return nil, nil, err
+	}
+
+	if r.group[g].PackedSize != 0 {
+		rc, err = packed.NewReadCloser(rc, offset)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return rc, nil, nil
+}
+
+// groupData returns the data of group g after decompression and skipping
+// any hash exceptions, but before any RVZ packing is undone.
+func (r *Reader) groupData(g int, partition bool) (rc io.ReadCloser, err error) {
 	group := r.group[g]
 
 	switch {
 	case group.compressed():
 		rc, err = r.decompressor(io.NewSectionReader(r.ra, group.offset(), group.size()))
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	case group.size() == 0:
-		rc = io.NopCloser(io.LimitReader(plumbing.DevZero(), r.disc.chunkSize(partition)))
+		size := r.disc.chunkSize(partition)
+		if partition {
+			// Allow for the empty list of exceptions
+			size += int64(binary.Size(uint16(0)))
+		}
+
+		rc = io.NopCloser(io.LimitReader(plumbing.DevZero(), size))
 	default:
 		rc = io.NopCloser(io.NewSectionReader(r.ra, group.offset(), group.size()))
 	}
@@ -183,61 +268,66 @@ func (r *reader) groupReader(g int, offset int64, partition bool) (rc io.ReadClo
 
 		var numExceptions uint16
 		if err = binary.Read(tr, binary.BigEndian, &numExceptions); err != nil {
-			return nil, nil, err
+			rc.Close()
+
+			return nil, err
 		}
 
 		if numExceptions > 0 {
-			return nil, nil, errors.New("TODO handle exceptions")
+			rc.Close()
+
+			return nil, ErrHashExceptions
 		}
 
 		// No compression, data starts on the next 4 byte boundary
-		if !group.compressed() {
+		if !group.compressed() && group.size() > 0 {
 			if _, err = io.CopyN(io.Discard, rc, (group.offset()+int64(wc.Count()))%4); err != nil {
-				return nil, nil, err
-			}
-		}
-	}
+				rc.Close()
 
-	if group.PackedSize != 0 {
-		rc, err = packed.NewReadCloser(rc, offset)
-		if err != nil {
-			return nil, nil, err
+				return nil, err
+			}
 		}
 	}
 
-	return rc, nil, nil
+	return rc, nil
 }
 
-func (r *reader) nextReader() (err error) {
-	for i, x := range r.raw {
-		if r.offset == int64(x.RawDataOff) {
-			r.r = newRawReader(r, i)
-
-			return
-		}
+// decodeError wraps err with the location it occurred at, unless it already
+// carries one. Running out of data part way through a group is reported as
+// ErrShortGroup.
+func (r *Reader) decodeError(err error, offset int64, g, p int) error {
+	var de *DecodeError
+	if errors.As(err, &de) {
+		return err
 	}
 
-	for i, x := range r.part {
-		for j := range x.Data {
-			if r.offset == int64(x.Data[j].FirstSector)*util.SectorSize && x.Data[j].NumSector > 0 {
-				r.r = newPartReader(r, i, j)
-
-				return
-			}
-		}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = ErrShortGroup
 	}
 
-	return errors.New("rvz: cannot find reader")
+	return &DecodeError{
+		Offset:    offset,
+		Group:     g,
+		Partition: p,
+		Method:    r.disc.Compression,
+		Err:       err,
+	}
 }
 
-func (r *reader) Read(p []byte) (n int, err error) {
-	if r.offset == int64(r.header.IsoFileSize) {
+// Read reads the disc image in order, decoding each group or cluster as it
+// is reached.
+func (r *Reader) Read(p []byte) (n int, err error) {
+	if err = r.err(); err != nil {
+		return 0, err
+	}
+
+	if r.offset >= int64(r.header.IsoFileSize) {
 		return 0, io.EOF
 	}
 
 	if r.r == nil {
-		if err = r.nextReader(); err != nil {
-			return
+		if r.r, err = r.sectionReader(r.offset, false); err != nil {
+			return 0, r.decodeError(err, r.offset, -1, -1)
 		}
 	}
 
@@ -255,11 +345,96 @@ func (r *reader) Read(p []byte) (n int, err error) {
 	return
 }
 
-func (r *reader) Size() int64 {
+// Seek sets the offset in the disc image for the next Read or WriteTo. The
+// group or cluster holding the new offset has to be decoded again up to
+// that point, so seeking within it isn't free.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	if err := r.err(); err != nil {
+		return 0, err
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += int64(r.header.IsoFileSize)
+	default:
+		return 0, fmt.Errorf("%w: whence %d", ErrOffset, whence)
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrOffset, offset)
+	}
+
+	if offset != r.offset {
+		closeReader(r.r)
+		r.r = nil
+		r.offset = offset
+	}
+
+	return offset, nil
+}
+
+// ReadAt reads len(p) bytes from offset in the disc image, decoding only the
+// groups or clusters covering them. It doesn't affect the offset used by
+// Read and WriteTo.
+func (r *Reader) ReadAt(p []byte, offset int64) (int, error) {
+	if err := r.err(); err != nil {
+		return 0, err
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrOffset, offset)
+	}
+
+	n, err := r.readAt(p, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = r.decodeError(err, offset+int64(n), -1, -1)
+	}
+
+	return n, err
+}
+
+// err returns why the reader can no longer be used, if at all.
+func (r *Reader) err() error {
+	if r.closed {
+		return ErrClosed
+	}
+
+	return r.ctx.Err()
+}
+
+// Close releases any buffers and decompressors held by the reader, and
+// closes the file if the reader was returned by Open.
+func (r *Reader) Close() (err error) {
+	if r.closed {
+		return ErrClosed
+	}
+
+	r.closed = true
+
+	if c, ok := r.r.(io.Closer); ok {
+		err = c.Close()
+	}
+
+	r.r = nil
+
+	if r.file != nil {
+		if ferr := r.file.Close(); err == nil {
+			err = ferr
+		}
+	}
+
+	return err
+}
+
+// Size returns the size of the disc image.
+func (r *Reader) Size() int64 {
 	return int64(r.header.IsoFileSize)
 }
 
-func (r *reader) readRaw() error {
+func (r *Reader) readRaw() error {
 	cr, err := r.decompressor(r.disc.rawReader(r.ra))
 	if err != nil {
 		return err
@@ -282,7 +457,7 @@ func (r *reader) readRaw() error {
 	return nil
 }
 
-func (r *reader) readGroup() error {
+func (r *Reader) readGroup() error {
 	cr, err := r.decompressor(r.disc.groupReader(r.ra))
 	if err != nil {
 		return err
@@ -294,13 +469,68 @@ func (r *reader) readGroup() error {
 	return binary.Read(cr, binary.BigEndian, &r.group)
 }
 
-// NewReader returns a new io.Reader that reads and decompresses from ra.
-//
-//nolint:cyclop,funlen
-func NewReader(ra io.ReaderAt) (Reader, error) {
-	r := new(reader)
+// NewReader returns a new Reader that reads and decompresses from ra,
+// configured by any options.
+func NewReader(ra io.ReaderAt, opts ...Option) (*Reader, error) {
+	return newReader(context.Background(), ra, opts...)
+}
+
+// Open opens the named RVZ file and returns a Reader for it, configured by
+// any options. Closing the Reader also closes the file.
+func Open(name string, opts ...Option) (*Reader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newReader(context.Background(), f, opts...)
+	if err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	r.file = f
+
+	return r, nil
+}
+
+// NewReaderContext is like NewReader but ctx is used for the lifetime of
+// the Reader. Once ctx is cancelled any decoding in progress stops promptly
+// and every method returns the context's error.
+func NewReaderContext(ctx context.Context, ra io.ReaderAt, opts ...Option) (*Reader, error) {
+	return newReader(ctx, ra, opts...)
+}
+
+func newReader(ctx context.Context, ra io.ReaderAt, opts ...Option) (*Reader, error) {
+	r := new(Reader)
 	r.ra = ra
+	r.ctx = ctx
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.setOptions(opts); err != nil {
+		return nil, err
+	}
+
+	if err := r.readHeader(); err != nil {
+		return nil, err
+	}
 
+	if err := r.readTables(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// readHeader reads and verifies the header and disc structs.
+//
+//nolint:cyclop
+func (r *Reader) readHeader() error {
+	ra := r.ra
 	h := sha1.New() //nolint:gosec
 
 	size := int64(binary.Size(r.header)) - sha1.Size
@@ -308,37 +538,37 @@ func NewReader(ra io.ReaderAt) (Reader, error) {
 	// Create a reader that can read the whole struct, but the SHA1 hash at the end is excluded
 	mr := io.MultiReader(io.TeeReader(io.NewSectionReader(ra, 0, size), h), io.NewSectionReader(ra, size, sha1.Size))
 	if err := binary.Read(mr, binary.BigEndian, &r.header); err != nil {
-		return nil, err
+		return err
 	}
 
 	if r.header.Magic != rvzMagic {
-		return nil, errors.New("rvz: bad magic")
+		return ErrBadMagic
 	}
 
-	if !bytes.Equal(r.header.FileHeadHash[:], h.Sum(nil)) {
-		return nil, errors.New("rvz: header hash doesn't match")
+	if r.checks&HeaderHash != 0 && !bytes.Equal(r.header.FileHeadHash[:], h.Sum(nil)) {
+		return ErrHeaderHash
 	}
 
 	h.Reset()
 
 	if int(r.header.DiscSize) != binary.Size(r.disc) {
-		return nil, errors.New("rvz: disc struct has wrong size")
+		return ErrDiscSize
 	}
 
 	if err := binary.Read(io.TeeReader(r.header.discReader(ra), h), binary.BigEndian, &r.disc); err != nil {
-		return nil, err
+		return err
 	}
 
-	if !bytes.Equal(r.header.DiscHash[:], h.Sum(nil)) {
-		return nil, errors.New("rvz: disc hash doesn't match")
+	if r.checks&DiscHash != 0 && !bytes.Equal(r.header.DiscHash[:], h.Sum(nil)) {
+		return ErrDiscHash
 	}
 
 	switch r.disc.DiscType {
-	case gameCube:
-	case wii:
+	case discGameCube:
+	case discWii:
 		break
 	default:
-		return nil, errors.New("rvz: invalid disc type")
+		return ErrDiscType
 	}
 
 	switch r.disc.ChunkSize {
@@ -351,33 +581,43 @@ func NewReader(ra io.ReaderAt) (Reader, error) {
 	case util.SectorSize << 6: //   2 MiB
 		break
 	default:
-		return nil, errors.New("rvz: bad chunk size")
+		return ErrChunkSize
 	}
 
-	h.Reset()
+	return nil
+}
+
+// readTables reads the partition, raw data and group tables.
+func (r *Reader) readTables() error {
+	if err := r.checkTables(); err != nil {
+		return err
+	}
+
+	h := sha1.New() //nolint:gosec
 
 	if r.disc.NumPart > 0 {
-		r.part = make([]part, r.disc.NumPart)
-		if int(r.disc.PartSize) != binary.Size(r.part[0]) {
-			return nil, errors.New("rvz: part struct has wrong size")
+		if int(r.disc.PartSize) != binary.Size(part{}) {
+			return ErrPartSize
 		}
 
-		if err := binary.Read(io.TeeReader(r.disc.partReader(ra), h), binary.BigEndian, &r.part); err != nil {
-			return nil, err
+		r.part = make([]part, r.disc.NumPart)
+
+		if err := binary.Read(io.TeeReader(r.disc.partReader(r.ra), h), binary.BigEndian, &r.part); err != nil {
+			return err
 		}
 	}
 
-	if !bytes.Equal(r.disc.PartHash[:], h.Sum(nil)) {
-		return nil, errors.New("rvz: partition hash doesn't match")
+	if r.checks&PartHash != 0 && !bytes.Equal(r.disc.PartHash[:], h.Sum(nil)) {
+		return ErrPartHash
 	}
 
 	if err := r.readRaw(); err != nil {
-		return nil, err
+		return err
 	}
 
 	if err := r.readGroup(); err != nil {
-		return nil, err
+		return err
 	}
 
-	return r, nil
+	return r.checkEntries()
 }
@@ -1,6 +1,7 @@
 package rvz_test
 
 import (
+	"bytes"
 	"crypto/sha1" //nolint:gosec
 	"encoding/xml"
 	"errors"
@@ -17,8 +18,8 @@ import (
 )
 
 const (
-	gamecube = "Nintendo - GameCube - Datfile (1942) (2022-05-22 04-27-22).dat"
-	wii      = "Nintendo - Wii - Datfile (3647) (2022-01-07 22-05-54).dat"
+	gamecubeDat = "Nintendo - GameCube - Datfile (1942) (2022-05-22 04-27-22).dat"
+	wiiDat      = "Nintendo - Wii - Datfile (3647) (2022-01-07 22-05-54).dat"
 )
 
 //nolint:cyclop,funlen
@@ -34,17 +35,17 @@ func TestReader(t *testing.T) {
 	}{
 		{
 			name: "GameCube",
-			dat:  gamecube,
+			dat:  gamecubeDat,
 			file: "Gekkan Nintendo Tentou Demo 2003.9.1 (Japan)",
 		},
 		{
 			name: "Wii",
-			dat:  wii,
+			dat:  wiiDat,
 			file: "Metal Slug Anthology (USA)",
 		},
 		{
 			name: "Issue #121",
-			dat:  wii,
+			dat:  wiiDat,
 			file: "Mario Kart Wii (USA) (En,Fr,Es)",
 		},
 	}
@@ -127,3 +128,481 @@ func BenchmarkReader(b *testing.B) {
 		}
 	}
 }
+
+func TestSynthetic(t *testing.T) {
+	t.Parallel()
+
+	iso := testISO(20)
+
+	tables := []struct {
+		name      string
+		method    uint32
+		chunkSize uint32
+	}{
+		{
+			name:   "None",
+			method: methodNone,
+		},
+		{
+			name:   "Bzip2",
+			method: methodBzip2,
+		},
+		{
+			name:   "LZMA",
+			method: methodLZMA,
+		},
+		{
+			name:   "LZMA2",
+			method: methodLZMA2,
+		},
+		{
+			name:      "LZMA22MiB",
+			method:    methodLZMA2,
+			chunkSize: sectorSize * clusterSectors,
+		},
+		{
+			name:   "Zstandard",
+			method: methodZstd,
+		},
+		{
+			name:      "Zstandard32KiB",
+			method:    methodZstd,
+			chunkSize: sectorSize,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			ti := &testImage{method: table.method, chunkSize: table.chunkSize, iso: iso}
+
+			r, err := rvz.NewReader(bytes.NewReader(ti.build(t)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, int64(len(iso)), r.Size())
+			assert.True(t, bytes.Equal(iso, b))
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name        string
+		corrupt     func([]byte)
+		target      error
+		unsupported bool
+	}{
+		{
+			name:    "BadMagic",
+			corrupt: func(b []byte) { b[0] = 'X' },
+			target:  rvz.ErrBadMagic,
+		},
+		{
+			name:    "HeaderHash",
+			corrupt: func(b []byte) { b[0x10] ^= 0xff },
+			target:  rvz.ErrHeaderHash,
+		},
+		{
+			name:    "DiscHash",
+			corrupt: func(b []byte) { b[0x48+0x20] ^= 0xff },
+			target:  rvz.ErrDiscHash,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := (&testImage{iso: testISO(4)}).build(t)
+			table.corrupt(b)
+
+			_, err := rvz.NewReader(bytes.NewReader(b))
+			assert.ErrorIs(t, err, table.target)
+			assert.ErrorIs(t, err, rvz.ErrCorrupt)
+			assert.NotErrorIs(t, err, rvz.ErrUnsupported)
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	t.Parallel()
+
+	ti := &testImage{
+		iso: testISO(16),
+		mangle: func(groups []testGroup) {
+			// Truncate the third group
+			groups[2].Size = 0x100
+		},
+	}
+
+	r, err := rvz.NewReader(bytes.NewReader(ti.build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = io.Copy(io.Discard, r)
+
+	var de *rvz.DecodeError
+
+	if assert.ErrorAs(t, err, &de) {
+		assert.Equal(t, 2, de.Group)
+		assert.Equal(t, -1, de.Partition)
+		assert.ErrorIs(t, err, rvz.ErrShortGroup)
+	}
+}
+
+func FuzzNewReader(f *testing.F) {
+	iso := testISO(8)
+
+	f.Add((&testImage{iso: iso}).build(f))
+	f.Add((&testImage{method: methodZstd, iso: iso}).build(f))
+	f.Add((&testImage{method: methodZstd, chunkSize: sectorSize, iso: iso}).build(f))
+	f.Add((&testImage{method: methodBzip2, iso: iso}).build(f))
+	f.Add((&testImage{method: methodLZMA, iso: iso}).build(f))
+	f.Add((&testImage{method: methodLZMA2, iso: iso}).build(f))
+
+	wiiISO, tp := testWiiISO(f, 70)
+	f.Add((&testImage{method: methodZstd, iso: wiiISO, partitions: []testPartition{tp}}).build(f))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		rehash(b)
+
+		r, err := rvz.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+
+		_, _ = io.Copy(io.Discard, io.LimitReader(r, 1<<24))
+		_, _ = r.VerifyPartitions()
+		_, _ = r.Partitions()
+	})
+}
+
+func TestWii(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+
+	tables := []struct {
+		name      string
+		method    uint32
+		chunkSize uint32
+	}{
+		{
+			name:   "None",
+			method: methodNone,
+		},
+		{
+			name:   "LZMA",
+			method: methodLZMA,
+		},
+		{
+			name:      "Zstandard32KiB",
+			method:    methodZstd,
+			chunkSize: sectorSize,
+		},
+		{
+			name:      "Zstandard2MiB",
+			method:    methodZstd,
+			chunkSize: sectorSize * clusterSectors,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			ti := &testImage{
+				method:     table.method,
+				chunkSize:  table.chunkSize,
+				iso:        iso,
+				partitions: []testPartition{tp},
+			}
+
+			b := ti.build(t)
+
+			assert.NoError(t, rvz.Check(bytes.NewReader(b), int64(len(b))))
+
+			r, err := rvz.NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.True(t, bytes.Equal(iso, out))
+
+			mismatches, err := r.VerifyPartitions()
+			assert.NoError(t, err)
+			assert.Empty(t, mismatches)
+		})
+	}
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	open *int
+}
+
+func (rc *countingReadCloser) Close() error {
+	*rc.open--
+
+	return rc.ReadCloser.Close()
+}
+
+//nolint:funlen
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	var open int
+
+	zstd := rvz.LookupDecompressor(methodZstd)
+	if zstd == nil {
+		t.Fatal("no zstd decompressor")
+	}
+
+	dcomp := func(b []byte, r io.Reader) (io.ReadCloser, error) {
+		rc, err := zstd(b, r)
+		if err != nil {
+			return nil, err
+		}
+
+		open++
+
+		return &countingReadCloser{rc, &open}, nil
+	}
+
+	name := filepath.Join(t.TempDir(), "test.rvz")
+	if err := os.WriteFile(name, (&testImage{method: methodZstd, iso: testISO(8)}).build(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := rvz.Open(name, rvz.WithDecompressor(methodZstd, dcomp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stop part way through a group
+	if _, err = io.ReadFull(r, make([]byte, sectorSize+100)); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, open)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, 0, open)
+
+	_, err = r.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, rvz.ErrClosed)
+
+	_, err = r.WriteTo(io.Discard)
+	assert.ErrorIs(t, err, rvz.ErrClosed)
+
+	_, err = r.Partitions()
+	assert.ErrorIs(t, err, rvz.ErrClosed)
+
+	assert.ErrorIs(t, r.Close(), rvz.ErrClosed)
+
+	_, err = rvz.Open(filepath.Join(t.TempDir(), "missing.rvz"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPacked(t *testing.T) {
+	t.Parallel()
+
+	iso := testISO(20)
+	seeds := testJunk(t, iso, 1, 2, 5, 19)
+
+	for _, table := range []struct {
+		name      string
+		method    uint32
+		chunkSize uint32
+	}{
+		{"None", methodNone, 0},
+		{"Zstandard", methodZstd, 0},
+		{"Zstandard32KiB", methodZstd, sectorSize},
+	} {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := (&testImage{method: table.method, chunkSize: table.chunkSize, iso: iso, seeds: seeds}).build(t)
+
+			r, err := rvz.NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.True(t, bytes.Equal(iso, out))
+
+			r, err = rvz.NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf := new(bytes.Buffer)
+			if _, err = r.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.True(t, bytes.Equal(iso, buf.Bytes()))
+		})
+	}
+}
+
+func TestSeek(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+	b := (&testImage{
+		method:     methodZstd,
+		chunkSize:  sectorSize,
+		iso:        iso,
+		partitions: []testPartition{tp},
+	}).build(t)
+
+	dataStart := int64(tp.firstSector) * sectorSize
+
+	offsets := []int64{
+		0,
+		0x1234,
+		testPartOffset,
+		dataStart,
+		dataStart + 70*sectorSize + 0x567,
+		int64(len(iso)) - 0x10,
+		int64(len(iso)),
+	}
+
+	for _, offset := range offsets {
+		offset := offset
+
+		t.Run(fmt.Sprintf("%#x", offset), func(t *testing.T) {
+			t.Parallel()
+
+			r, err := rvz.NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Start reading somewhere else first
+			if _, err = io.ReadFull(r, make([]byte, 0x100)); err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := r.Seek(offset, io.SeekStart)
+			assert.NoError(t, err)
+			assert.Equal(t, offset, n)
+
+			out, err := io.ReadAll(struct{ io.Reader }{r})
+			assert.NoError(t, err)
+			assert.True(t, bytes.Equal(iso[offset:], out))
+
+			_, err = r.Seek(offset-int64(len(iso)), io.SeekEnd)
+			assert.NoError(t, err)
+
+			buf := new(bytes.Buffer)
+			_, err = r.WriteTo(buf)
+			assert.NoError(t, err)
+			assert.True(t, bytes.Equal(iso[offset:], buf.Bytes()))
+
+			p := make([]byte, 0x9000)
+			m, err := r.ReadAt(p, offset)
+
+			if end := offset + int64(len(p)); end > int64(len(iso)) {
+				assert.ErrorIs(t, err, io.EOF)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.True(t, bytes.Equal(iso[offset:offset+int64(m)], p[:m]))
+		})
+	}
+
+	r, err := rvz.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.Seek(-1, io.SeekStart)
+	assert.ErrorIs(t, err, rvz.ErrOffset)
+
+	_, err = r.Seek(0, 3)
+	assert.ErrorIs(t, err, rvz.ErrOffset)
+
+	_, err = r.ReadAt(make([]byte, 1), -1)
+	assert.ErrorIs(t, err, rvz.ErrOffset)
+}
+
+func TestReadAtSmall(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+	b := (&testImage{
+		method:     methodZstd,
+		chunkSize:  sectorSize,
+		iso:        iso,
+		partitions: []testPartition{tp},
+	}).build(t)
+
+	r, err := rvz.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const size = 0x1000
+
+	// Two goroutines read alternate pieces of the disc image
+	out := make([]byte, len(iso))
+	done := make(chan error, 2)
+
+	for i := 0; i < 2; i++ {
+		i := i
+
+		go func() {
+			for off := i * size; off < len(out); off += 2 * size {
+				end := off + size
+				if end > len(out) {
+					end = len(out)
+				}
+
+				if _, err := r.ReadAt(out[off:end], int64(off)); err != nil {
+					done <- err
+
+					return
+				}
+			}
+
+			done <- nil
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, <-done)
+	}
+
+	assert.True(t, bytes.Equal(iso, out))
+}
@@ -0,0 +1,187 @@
+package rvz
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/bodgit/rvz/internal/util"
+)
+
+type region struct {
+	offset, size int64
+	groupIndex   uint32
+	numGroup     uint32
+	raw          int // index into the raw data entries, or -1
+	part, data   int // indices into the partition entries
+}
+
+func (rg region) partition() int {
+	if rg.raw < 0 {
+		return rg.part
+	}
+
+	return -1
+}
+
+// regions returns the raw data and partition data regions sorted by their
+// offset in the disc image. Empty regions are skipped.
+func (r *Reader) regions() []region {
+	regions := make([]region, 0, len(r.raw)+len(r.part)*len(part{}.Data))
+
+	for i, x := range r.raw {
+		if x.RawDataSize == 0 {
+			continue
+		}
+
+		regions = append(regions, region{
+			offset:     int64(x.RawDataOff),
+			size:       int64(x.RawDataSize),
+			groupIndex: x.GroupIndex,
+			numGroup:   x.NumGroup,
+			raw:        i,
+		})
+	}
+
+	for i, x := range r.part {
+		for j, d := range x.Data {
+			if d.NumSector == 0 {
+				continue
+			}
+
+			regions = append(regions, region{
+				offset:     int64(d.FirstSector) * util.SectorSize,
+				size:       int64(d.NumSector) * util.SectorSize,
+				groupIndex: d.GroupIndex,
+				numGroup:   d.NumGroup,
+				raw:        -1,
+				part:       i,
+				data:       j,
+			})
+		}
+	}
+
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].offset < regions[j].offset
+	})
+
+	return regions
+}
+
+// groupLocation returns the offset in the disc image covered by group g and
+// the partition it belongs to, or -1 if it's raw data.
+func (r *Reader) groupLocation(g int) (int64, int) {
+	for _, rg := range r.regions() {
+		if i := int64(g) - int64(rg.groupIndex); i >= 0 && i < int64(rg.numGroup) {
+			return rg.offset + i*r.disc.chunkSize(false), rg.partition()
+		}
+	}
+
+	return -1, -1
+}
+
+// regionAt returns the region containing offset in the disc image.
+func (r *Reader) regionAt(offset int64) (region, bool) {
+	for _, rg := range r.regions() {
+		if offset >= rg.offset && offset < rg.offset+rg.size {
+			return rg, true
+		}
+	}
+
+	return region{}, false
+}
+
+// sectionReader returns an io.Reader that starts at offset in the disc image
+// and continues to the end of the region containing it. If quiet is set then
+// it doesn't report progress.
+func (r *Reader) sectionReader(offset int64, quiet bool) (io.Reader, error) {
+	for _, rg := range r.regions() {
+		if offset < rg.offset || offset >= rg.offset+rg.size {
+			continue
+		}
+
+		var (
+			rd   io.Reader
+			skip = offset - rg.offset
+		)
+
+		// Start at the group or cluster containing offset
+		if rg.raw < 0 {
+			pr := newPartReader(r, rg.part, rg.data)
+			pr.sector = int(skip/groupSize) * clusters
+			pr.quiet = quiet
+			skip %= groupSize
+			rd = pr
+		} else {
+			rr := newRawReader(r, rg.raw)
+			n := skip / r.disc.chunkSize(false)
+			rr.g += int(n)
+			rr.quiet = quiet
+			rr.offset += n * r.disc.chunkSize(false)
+			skip -= n * r.disc.chunkSize(false)
+			rd = rr
+		}
+
+		if _, err := io.CopyN(io.Discard, rd, skip); err != nil {
+			closeReader(rd)
+
+			return nil, err
+		}
+
+		return rd, nil
+	}
+
+	return nil, ErrNoRegion
+}
+
+// closeReader releases any group reader still held by rd.
+func closeReader(rd io.Reader) {
+	if c, ok := rd.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// readAt reads len(p) bytes from offset in the disc image.
+func (r *Reader) readAt(p []byte, offset int64) (n int, err error) {
+	for n < len(p) {
+		if offset+int64(n) >= int64(r.header.IsoFileSize) {
+			return n, io.EOF
+		}
+
+		var (
+			rd io.Reader
+			m  int
+		)
+
+		if rg, ok := r.regionAt(offset + int64(n)); ok && rg.raw < 0 {
+			if m, err = r.at.readAt(r, rg.part, rg.data, p[n:], offset+int64(n)-rg.offset); err != nil {
+				return n, err
+			}
+
+			n += m
+
+			continue
+		}
+
+		if rd, err = r.sectionReader(offset+int64(n), true); err != nil {
+			return n, err
+		}
+
+		m, err = io.ReadFull(rd, p[n:])
+		n += m
+
+		closeReader(rd)
+
+		switch {
+		case err == nil:
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			if m == 0 {
+				return n, ErrShortGroup
+			}
+		default:
+			return n, err
+		}
+	}
+
+	return n, nil
+}
@@ -2,8 +2,8 @@ package rvz
 
 import (
 	"compress/bzip2"
-	"errors"
 	"io"
+	"sort"
 	"sync"
 
 	"github.com/bodgit/rvz/internal/lzma"
@@ -13,7 +13,9 @@ import (
 
 // Decompressor describes the function signature that decompression methods
 // must implement to return a new instance of themselves. They are passed any
-// property bytes and an io.Reader providing the stream of bytes.
+// property bytes and an io.Reader providing the stream of bytes. An error
+// that matches ErrUnsupported is returned as is, anything else is treated as
+// the image being corrupt.
 type Decompressor func([]byte, io.Reader) (io.ReadCloser, error)
 
 //nolint:gochecknoglobals
@@ -27,7 +29,7 @@ func init() {
 	}))
 	// Purge. RVZ removed support for this algorithm from the original WIA format
 	RegisterDecompressor(1, Decompressor(func(_ []byte, _ io.Reader) (io.ReadCloser, error) {
-		return nil, errors.New("purge method not supported")
+		return nil, UnsupportedMethodError(1)
 	}))
 	// Bzip2
 	RegisterDecompressor(2, Decompressor(func(_ []byte, r io.Reader) (io.ReadCloser, error) {
@@ -42,10 +44,45 @@ func init() {
 }
 
 // RegisterDecompressor allows custom decompressors for the specified method.
+// Registering a method again replaces the previous Decompressor, so the
+// defaults can be swapped for something faster or instrumented, and
+// registering nil removes the method. A Decompressor can instead be used by
+// a single Reader with WithDecompressor.
 func RegisterDecompressor(method uint32, dcomp Decompressor) {
-	if _, dup := decompressors.LoadOrStore(method, dcomp); dup {
-		panic("decompressor already registered")
+	if dcomp == nil {
+		decompressors.Delete(method)
+
+		return
 	}
+
+	decompressors.Store(method, dcomp)
+}
+
+// LookupDecompressor returns the Decompressor registered for the specified
+// method, or nil if there isn't one. This allows a replacement to wrap the
+// original.
+func LookupDecompressor(method uint32) Decompressor {
+	return decompressor(method)
+}
+
+// Methods returns the compression methods that have a registered
+// Decompressor, in ascending order.
+func Methods() []uint32 {
+	var methods []uint32
+
+	decompressors.Range(func(k, _ interface{}) bool {
+		if method, ok := k.(uint32); ok {
+			methods = append(methods, method)
+		}
+
+		return true
+	})
+
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i] < methods[j]
+	})
+
+	return methods
 }
 
 func decompressor(method uint32) Decompressor {
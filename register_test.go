@@ -0,0 +1,118 @@
+package rvz_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsupportedMethod(t *testing.T) {
+	t.Parallel()
+
+	_, err := rvz.NewReader(bytes.NewReader((&testImage{method: 42, iso: testISO(4)}).build(t)))
+
+	var ume rvz.UnsupportedMethodError
+
+	assert.ErrorAs(t, err, &ume)
+	assert.Equal(t, rvz.UnsupportedMethodError(42), ume)
+	assert.ErrorIs(t, err, rvz.ErrUnsupported)
+}
+
+func TestPurge(t *testing.T) {
+	t.Parallel()
+
+	// Mark the groups as compressed so the purge Decompressor is used
+	b := (&testImage{method: methodPurge, iso: testISO(4), mangle: func(groups []testGroup) {
+		for i := range groups {
+			groups[i].Size |= 1 << 31
+		}
+	}}).build(t)
+
+	_, err := rvz.NewReader(bytes.NewReader(b))
+
+	var ume rvz.UnsupportedMethodError
+
+	assert.ErrorAs(t, err, &ume)
+	assert.Equal(t, rvz.UnsupportedMethodError(methodPurge), ume)
+	assert.ErrorIs(t, err, rvz.ErrUnsupported)
+	assert.NotErrorIs(t, err, rvz.ErrCorrupt)
+}
+
+//nolint:paralleltest
+func TestRegisterDecompressor(t *testing.T) {
+	const method = 6
+
+	assert.Equal(t, []uint32{0, 1, 2, 3, 4, 5}, rvz.Methods())
+	assert.Nil(t, rvz.LookupDecompressor(method))
+
+	var calls int
+
+	dcomp := func(_ []byte, r io.Reader) (io.ReadCloser, error) {
+		calls++
+
+		return io.NopCloser(r), nil
+	}
+
+	// Registering the same method twice replaces the first one
+	assert.NotPanics(t, func() {
+		rvz.RegisterDecompressor(method, rvz.LookupDecompressor(methodNone))
+		rvz.RegisterDecompressor(method, dcomp)
+	})
+	assert.Equal(t, []uint32{0, 1, 2, 3, 4, 5, 6}, rvz.Methods())
+
+	b := (&testImage{method: method, iso: testISO(4)}).build(t)
+
+	r, err := rvz.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = io.Copy(io.Discard, r)
+	assert.NoError(t, err)
+	assert.NotZero(t, calls)
+
+	rvz.RegisterDecompressor(method, nil)
+	assert.Nil(t, rvz.LookupDecompressor(method))
+
+	_, err = rvz.NewReader(bytes.NewReader(b))
+	assert.ErrorIs(t, err, rvz.ErrUnsupported)
+}
+
+func BenchmarkMethods(b *testing.B) {
+	iso := testISO(256)
+
+	for _, table := range []struct {
+		name   string
+		method uint32
+	}{
+		{"None", methodNone},
+		{"Bzip2", methodBzip2},
+		{"LZMA", methodLZMA},
+		{"LZMA2", methodLZMA2},
+		{"Zstandard", methodZstd},
+	} {
+		table := table
+
+		b.Run(table.name, func(b *testing.B) {
+			rb := (&testImage{method: table.method, iso: iso}).build(b)
+
+			b.SetBytes(int64(len(iso)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				r, err := rvz.NewReader(bytes.NewReader(rb))
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				if _, err = io.Copy(io.Discard, r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
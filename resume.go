@@ -0,0 +1,77 @@
+package rvz
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bodgit/rvz/internal/util"
+)
+
+// ResumeOffset returns where to carry on writing a disc image of imageSize
+// bytes that was interrupted after size bytes were written to partial. Up to
+// check bytes at the end of the whole sectors already written are compared,
+// a sector at a time, against the disc image read from r, which can be a
+// Reader or a Scrubber, and the offset of the first sector that doesn't match
+// is returned. If they all match then the offset of the end of the last whole
+// sector, or of the disc image if it was written completely, is returned.
+// Anything before the bytes that are checked is assumed to be correct. It
+// returns ErrResumeSize if partial is larger than the disc image.
+func ResumeOffset(r, partial io.ReaderAt, imageSize, size, check int64) (int64, error) {
+	if size > imageSize {
+		return 0, fmt.Errorf("%w: %d bytes written of %d", ErrResumeSize, size, imageSize)
+	}
+
+	end := size - size%util.SectorSize
+	if size == imageSize {
+		// The last sector of the disc image can be short
+		end = size
+	}
+
+	if end <= 0 {
+		return 0, nil
+	}
+
+	start := end - check
+	if start < 0 {
+		start = 0
+	}
+
+	start -= start % util.SectorSize
+
+	want := make([]byte, util.SectorSize)
+	got := make([]byte, util.SectorSize)
+
+	for offset := start; offset < end; offset += util.SectorSize {
+		n := int64(util.SectorSize)
+		if end-offset < n {
+			n = end - offset
+		}
+
+		if err := readFullAt(r, want[:n], offset); err != nil {
+			return 0, err
+		}
+
+		if err := readFullAt(partial, got[:n], offset); err != nil {
+			return 0, err
+		}
+
+		if !bytes.Equal(want[:n], got[:n]) {
+			return offset, nil
+		}
+	}
+
+	return end, nil
+}
+
+// readFullAt reads exactly len(b) bytes from r at offset, ignoring an
+// io.EOF that comes with the last of them.
+func readFullAt(r io.ReaderAt, b []byte, offset int64) error {
+	n, err := r.ReadAt(b, offset)
+	if n == len(b) && errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,106 @@
+package rvz_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResumeOffset(t *testing.T) {
+	t.Parallel()
+
+	iso := testISO(20)
+
+	r, err := rvz.NewReader(bytes.NewReader((&testImage{method: methodZstd, iso: iso}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := map[string]struct {
+		partial []byte
+		offset  int64
+		err     error
+	}{
+		"empty": {
+			partial: nil,
+			offset:  0,
+		},
+		"partial sector": {
+			partial: iso[:5*sectorSize+0x100],
+			offset:  5 * sectorSize,
+		},
+		"complete": {
+			partial: iso,
+			offset:  int64(len(iso)),
+		},
+		"damaged": {
+			partial: func() []byte {
+				b := append([]byte(nil), iso[:10*sectorSize]...)
+				b[8*sectorSize+0x10] ^= 0xff
+
+				return b
+			}(),
+			offset: 8 * sectorSize,
+		},
+		"damaged before check": {
+			partial: func() []byte {
+				b := append([]byte(nil), iso[:10*sectorSize]...)
+				b[0x10] ^= 0xff
+
+				return b
+			}(),
+			offset: 10 * sectorSize,
+		},
+		"damaged last sector": {
+			partial: func() []byte {
+				b := append([]byte(nil), iso...)
+				b[len(b)-1] ^= 0xff
+
+				return b
+			}(),
+			offset: int64(len(iso) - sectorSize),
+		},
+		"too long": {
+			partial: append(append([]byte(nil), iso...), make([]byte, 2*sectorSize)...),
+			err:     rvz.ErrResumeSize,
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			offset, err := rvz.ResumeOffset(r, bytes.NewReader(table.partial), r.Size(), int64(len(table.partial)), 4*sectorSize)
+			if table.err != nil {
+				assert.ErrorIs(t, err, table.err)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, table.offset, offset)
+		})
+	}
+}
+
+func TestResumeOffsetShortSector(t *testing.T) {
+	t.Parallel()
+
+	// A disc image that doesn't end on a sector boundary
+	iso := testISO(20)[:19*sectorSize+0x100]
+	partial := append([]byte(nil), iso...)
+	size := int64(len(iso))
+
+	offset, err := rvz.ResumeOffset(bytes.NewReader(iso), bytes.NewReader(partial), size, size, 4*sectorSize)
+	assert.NoError(t, err)
+	assert.Equal(t, size, offset)
+
+	partial[len(partial)-1] ^= 0xff
+
+	offset, err = rvz.ResumeOffset(bytes.NewReader(iso), bytes.NewReader(partial), size, size, 4*sectorSize)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(19*sectorSize), offset)
+}
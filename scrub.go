@@ -0,0 +1,463 @@
+package rvz
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/bodgit/rvz/internal/util"
+	"github.com/bodgit/rvz/wii"
+)
+
+const (
+	bootSize      = 0x440
+	bootDOLOffset = 0x420
+	bootFSTOffset = 0x424
+	bootFSTSize   = 0x428
+
+	// The boot and bi2 headers are followed by the apploader
+	apploaderOffset     = 0x2440
+	apploaderHeaderSize = 0x20
+	apploaderSize       = 0x14
+	apploaderTrailer    = 0x18
+
+	dolHeaderSize = 0x100
+	dolSections   = 7 + 11 // text then data sections
+	dolSizes      = 0x90
+
+	fstEntrySize = 0xc
+	maxFSTSize   = 64 << 20
+
+	// The disc header, partition tables and region settings of a Wii disc
+	// all come before the first partition
+	wiiHeaderSize = 0x50000
+)
+
+// An Extent is a range of bytes in the disc image.
+type Extent struct {
+	Offset, Size int64
+}
+
+// End returns the offset just past the end of the extent.
+func (e Extent) End() int64 {
+	return e.Offset + e.Size
+}
+
+// partitionData reads the decrypted data of partition entry p, building
+// each cluster that is needed. The last cluster is kept until close is
+// called, as the file system is read in many small pieces.
+type partitionData struct {
+	r      *Reader
+	p      int
+	ws     *workspace
+	d      int
+	sector int // first sector of the cluster held in ws
+}
+
+func (pd *partitionData) cluster(d, sector int) (*workspace, error) {
+	if pd.ws != nil && pd.d == d && pd.sector == sector {
+		return pd.ws, nil
+	}
+
+	// Give back the old cluster first in case the pool is limited
+	pd.close()
+
+	pr := newPartReader(pd.r, pd.p, d)
+	pr.sector = sector
+
+	ws, err := pr.build(pd.r.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pd.ws, pd.d, pd.sector = ws, d, sector
+
+	return ws, nil
+}
+
+func (pd *partitionData) close() {
+	if pd.ws != nil {
+		pd.r.pool.put(pd.ws)
+		pd.ws = nil
+	}
+}
+
+func (pd *partitionData) ReadAt(b []byte, offset int64) (n int, err error) {
+	for n < len(b) {
+		s := (offset + int64(n)) / sectorDataSize
+		skip := int(offset + int64(n) - s*sectorDataSize)
+
+		d, i, err := pd.r.findSector(pd.p, int(s))
+		if err != nil {
+			return n, err
+		}
+
+		ws, err := pd.cluster(d, i-i%clusters)
+		if err != nil {
+			return n, err
+		}
+
+		end := min(clusters, int(pd.r.part[pd.p].Data[d].NumSector)-pd.sector)
+
+		for j := i % clusters; j < end && n < len(b); j++ {
+			n += copy(b[n:], ws.Data[j][skip:])
+			skip = 0
+		}
+	}
+
+	return n, nil
+}
+
+// markFileSystem calls mark with every range of the disc or partition data
+// read from ra that is used by the boot headers, the apploader, the main
+// executable, the file system table or a file. Offsets stored on a Wii disc
+// are shifted right by two.
+//
+//nolint:cyclop,funlen
+func markFileSystem(ra io.ReaderAt, shift uint, mark func(offset, size int64)) error {
+	boot := make([]byte, bootSize)
+	if _, err := ra.ReadAt(boot, 0); err != nil {
+		return err
+	}
+
+	mark(0, apploaderOffset)
+
+	apploader := make([]byte, apploaderHeaderSize)
+	if _, err := ra.ReadAt(apploader, apploaderOffset); err != nil {
+		return err
+	}
+
+	mark(apploaderOffset, apploaderHeaderSize+int64(binary.BigEndian.Uint32(apploader[apploaderSize:]))+
+		int64(binary.BigEndian.Uint32(apploader[apploaderTrailer:])))
+
+	if dolOffset := int64(binary.BigEndian.Uint32(boot[bootDOLOffset:])) << shift; dolOffset != 0 {
+		dol := make([]byte, dolHeaderSize)
+		if _, err := ra.ReadAt(dol, dolOffset); err != nil {
+			return err
+		}
+
+		var size int64 = dolHeaderSize
+
+		for i := 0; i < dolSections; i++ {
+			end := int64(binary.BigEndian.Uint32(dol[i*4:])) + int64(binary.BigEndian.Uint32(dol[dolSizes+i*4:]))
+			if end > size {
+				size = end
+			}
+		}
+
+		mark(dolOffset, size)
+	}
+
+	fstOffset := int64(binary.BigEndian.Uint32(boot[bootFSTOffset:])) << shift
+	fstSize := int64(binary.BigEndian.Uint32(boot[bootFSTSize:])) << shift
+
+	if fstOffset == 0 || fstSize == 0 {
+		return nil
+	}
+
+	if fstSize < fstEntrySize || fstSize > maxFSTSize {
+		return ErrFileSystem
+	}
+
+	mark(fstOffset, fstSize)
+
+	fst := make([]byte, fstSize)
+	if _, err := ra.ReadAt(fst, fstOffset); err != nil {
+		return err
+	}
+
+	entries := int64(binary.BigEndian.Uint32(fst[8:]))
+	if entries*fstEntrySize > fstSize {
+		return ErrFileSystem
+	}
+
+	for i := int64(1); i < entries; i++ {
+		e := fst[i*fstEntrySize:]
+		if e[0] != 0 {
+			continue // a directory
+		}
+
+		mark(int64(binary.BigEndian.Uint32(e[4:]))<<shift, int64(binary.BigEndian.Uint32(e[8:])))
+	}
+
+	return nil
+}
+
+// partitionIndex returns the partition entry holding the data of partition,
+// or -1 if there isn't one.
+func (r *Reader) partitionIndex(partition *Partition) int {
+	for p := range r.part {
+		if r.firstSector(p)*util.SectorSize == partition.Offset+partition.Header.DataOffset {
+			return p
+		}
+	}
+
+	return -1
+}
+
+// markPartitions calls mark with every range of a Wii disc image used by the
+// partition headers and the file system within each partition.
+func (r *Reader) markPartitions(mark func(offset, size int64)) error {
+	mark(0, wiiHeaderSize)
+
+	partitions, err := r.Partitions()
+	if err != nil {
+		return err
+	}
+
+	for i := range partitions {
+		partition := &partitions[i]
+		start := partition.Offset + partition.Header.DataOffset
+
+		mark(partition.Offset, partition.Header.DataOffset)
+		mark(partition.Offset+partition.Header.H3Offset, wii.H3Size)
+
+		p := r.partitionIndex(partition)
+		if p < 0 {
+			// Without the data the file system can't be read
+			mark(start, partition.Header.DataSize)
+
+			continue
+		}
+
+		pd := &partitionData{r: r, p: p}
+
+		// Each sector of decrypted data is a whole sector on the disc
+		err = markFileSystem(pd, 2, func(offset, size int64) {
+			if size <= 0 {
+				return
+			}
+
+			first, last := offset/sectorDataSize, (offset+size-1)/sectorDataSize
+			mark(start+first*util.SectorSize, (last-first+1)*util.SectorSize)
+		})
+
+		pd.close()
+
+		if err != nil {
+			return r.decodeError(err, start, -1, p)
+		}
+	}
+
+	return nil
+}
+
+// Unused returns the extents of the disc image that aren't used by the disc
+// header, the Wii partition headers, or the boot headers, apploader, main
+// executable, file system table or any file, in whole 32 KiB sectors. This
+// is where the junk data on a disc is found.
+func (r *Reader) Unused() ([]Extent, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+
+	size := r.Size()
+	used := make([]bool, (size+util.SectorSize-1)/util.SectorSize)
+
+	mark := func(offset, size int64) {
+		if size <= 0 || offset < 0 {
+			return
+		}
+
+		for s := offset / util.SectorSize; s <= (offset+size-1)/util.SectorSize && s < int64(len(used)); s++ {
+			used[s] = true
+		}
+	}
+
+	var err error
+
+	if r.disc.DiscType == discWii {
+		err = r.markPartitions(mark)
+	} else {
+		d := &discReaderAt{r: r}
+
+		err = markFileSystem(d, 0, mark)
+		if err != nil && d.err == nil && !errors.Is(err, ErrCorrupt) {
+			err = &dataError{fmt.Errorf("file system: %w", err)}
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var unused []Extent
+
+	for s := range used {
+		if used[s] {
+			continue
+		}
+
+		offset := int64(s) * util.SectorSize
+		if n := len(unused); n > 0 && unused[n-1].End() == offset {
+			unused[n-1].Size += util.SectorSize
+		} else {
+			unused = append(unused, Extent{Offset: offset, Size: util.SectorSize})
+		}
+	}
+
+	if n := len(unused); n > 0 && unused[n-1].End() > size {
+		unused[n-1].Size = size - unused[n-1].Offset
+	}
+
+	return unused, nil
+}
+
+// A Scrubber reads the disc image from a Reader with every extent returned
+// by its Unused method filled with zeroes, which removes the junk data and
+// anything else left behind on the disc. The result compresses much better
+// but the hashes of any Wii partition will no longer match. The Scrubber
+// must be used in place of the Reader.
+type Scrubber struct {
+	r      *Reader
+	unused []Extent
+	offset int64
+}
+
+// NewScrubber returns a new Scrubber reading from r, carrying on from
+// wherever r has already read up to.
+func NewScrubber(r *Reader) (*Scrubber, error) {
+	unused, err := r.Unused()
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Scrubber{r: r, unused: unused, offset: offset}, nil
+}
+
+// Scrubbed returns how many bytes of the whole disc image are filled with
+// zeroes.
+func (s *Scrubber) Scrubbed() (n int64) {
+	for _, e := range s.unused {
+		n += e.Size
+	}
+
+	return
+}
+
+// ScrubbedRange returns how many of the size bytes of the disc image from
+// offset are filled with zeroes.
+func (s *Scrubber) ScrubbedRange(offset, size int64) (n int64) {
+	end := offset + size
+
+	for i := s.find(offset); i < len(s.unused) && s.unused[i].Offset < end; i++ {
+		from, to := s.unused[i].Offset, s.unused[i].End()
+		if from < offset {
+			from = offset
+		}
+
+		if to > end {
+			to = end
+		}
+
+		n += to - from
+	}
+
+	return
+}
+
+// find returns the index of the first unused extent that ends after
+// offset.
+func (s *Scrubber) find(offset int64) int {
+	return sort.Search(len(s.unused), func(i int) bool {
+		return s.unused[i].End() > offset
+	})
+}
+
+// needsScrub returns true if any of the n bytes from offset are unused.
+func (s *Scrubber) needsScrub(offset, n int64) bool {
+	i := s.find(offset)
+
+	return i < len(s.unused) && s.unused[i].Offset < offset+n
+}
+
+// scrub zeroes any unused bytes in p, which starts at offset in the disc
+// image.
+func (s *Scrubber) scrub(p []byte, offset int64) {
+	end := offset + int64(len(p))
+
+	for i := s.find(offset); i < len(s.unused) && s.unused[i].Offset < end; i++ {
+		from, to := s.unused[i].Offset-offset, s.unused[i].End()-offset
+		if from < 0 {
+			from = 0
+		}
+
+		if to > int64(len(p)) {
+			to = int64(len(p))
+		}
+
+		for j := range p[from:to] {
+			p[from+int64(j)] = 0
+		}
+	}
+}
+
+// ReadAt reads len(p) bytes from offset in the disc image using the
+// Reader's ReadAt method.
+func (s *Scrubber) ReadAt(p []byte, offset int64) (int, error) {
+	n, err := s.r.ReadAt(p, offset)
+	s.scrub(p[:n], offset)
+
+	return n, err
+}
+
+// Seek sets the offset in the disc image for the next Read or WriteTo using
+// the Reader's Seek method.
+func (s *Scrubber) Seek(offset int64, whence int) (int64, error) {
+	offset, err := s.r.Seek(offset, whence)
+	if err == nil {
+		s.offset = offset
+	}
+
+	return offset, err
+}
+
+func (s *Scrubber) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.scrub(p[:n], s.offset)
+	s.offset += int64(n)
+
+	return n, err
+}
+
+// scrubWriter scrubs a copy of anything written before passing it on.
+type scrubWriter struct {
+	s   *Scrubber
+	w   io.Writer
+	buf []byte
+}
+
+func (sw *scrubWriter) Write(p []byte) (int, error) {
+	b := p
+
+	// Only copy p if some of it needs scrubbing
+	if sw.s.needsScrub(sw.s.offset, int64(len(p))) {
+		sw.buf = append(sw.buf[:0], p...)
+		b = sw.buf
+
+		sw.s.scrub(b, sw.s.offset)
+	}
+
+	n, err := sw.w.Write(b)
+	sw.s.offset += int64(n)
+
+	return n, err
+}
+
+// WriteTo implements the io.WriterTo interface.
+func (s *Scrubber) WriteTo(w io.Writer) (int64, error) {
+	return s.r.WriteTo(&scrubWriter{s: s, w: w})
+}
+
+// WriteToContext is like WriteTo but stops early if ctx is cancelled.
+func (s *Scrubber) WriteToContext(ctx context.Context, w io.Writer) (int64, error) {
+	return s.r.WriteToContext(ctx, &scrubWriter{s: s, w: w})
+}
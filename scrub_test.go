@@ -0,0 +1,141 @@
+package rvz_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrub(t *testing.T) {
+	t.Parallel()
+
+	files := [][2]uint32{{0x38000, 0x9000}, {0x50000, 0}, {0x58000, 0x100}}
+
+	gc := testISO(16)
+	testFileSystem(gc, 0, files...)
+
+	data := make([]byte, 16*sectorDataSize)
+	for i := range data {
+		data[i] = byte(i/0x100) | 1
+	}
+
+	testFileSystem(data, 2, files...)
+	wii, tp := testWiiImage(t, data)
+
+	// Sectors are scrubbed a whole sector at a time
+	extents := func(sectors ...[2]int64) []rvz.Extent {
+		e := make([]rvz.Extent, 0, len(sectors))
+		for _, s := range sectors {
+			e = append(e, rvz.Extent{Offset: s[0] * sectorSize, Size: (s[1] - s[0]) * sectorSize})
+		}
+
+		return e
+	}
+
+	tables := []struct {
+		name   string
+		iso    []byte
+		ti     *testImage
+		unused []rvz.Extent
+	}{
+		{
+			name:   "GameCube",
+			iso:    gc,
+			ti:     &testImage{method: methodZstd, iso: gc},
+			unused: extents([2]int64{1, 3}, [2]int64{4, 5}, [2]int64{6, 7}, [2]int64{9, 11}, [2]int64{12, 16}),
+		},
+		{
+			name: "Wii",
+			iso:  wii,
+			ti: &testImage{
+				method:     methodZstd,
+				iso:        wii,
+				partitions: []testPartition{tp},
+			},
+			unused: extents([2]int64{15, 17}, [2]int64{18, 19}, [2]int64{20, 21}, [2]int64{23, 25}, [2]int64{26, 34}),
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := table.ti.build(t)
+
+			want := append([]byte(nil), table.iso...)
+			for _, e := range table.unused {
+				copy(want[e.Offset:e.End()], make([]byte, e.Size))
+			}
+
+			// Both Read and WriteTo are scrubbed, including when the
+			// Reader has already been read from
+			for _, start := range []int64{0, sectorSize + 0x1234} {
+				for _, writeTo := range []bool{false, true} {
+					testScrub(t, b, want[start:], start, writeTo, table.unused)
+				}
+			}
+		})
+	}
+}
+
+func testScrub(t *testing.T, b, want []byte, start int64, writeTo bool, extents []rvz.Extent) {
+	t.Helper()
+
+	r, err := rvz.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unused, err := r.Unused()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, extents, unused)
+
+	if _, err = io.CopyN(io.Discard, r, start); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := rvz.NewScrubber(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var scrubbed, inRange int64
+
+	for _, e := range extents {
+		scrubbed += e.Size
+
+		if e.End() > start {
+			inRange += e.End() - e.Offset
+			if e.Offset < start {
+				inRange -= start - e.Offset
+			}
+		}
+	}
+
+	assert.Equal(t, scrubbed, s.Scrubbed())
+	assert.Equal(t, inRange, s.ScrubbedRange(start, int64(len(want))))
+
+	var out []byte
+
+	if writeTo {
+		buf := new(bytes.Buffer)
+		_, err = s.WriteTo(buf)
+		out = buf.Bytes()
+	} else {
+		out, err = io.ReadAll(s)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, bytes.Equal(want, out))
+}
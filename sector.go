@@ -0,0 +1,199 @@
+package rvz
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bodgit/rvz/internal/util"
+	"github.com/bodgit/rvz/wii"
+)
+
+// SectorFormat selects how ReadSector returns a sector.
+type SectorFormat int
+
+// The formats a sector can be returned in.
+const (
+	Encrypted SectorFormat = iota // exactly as stored on the disc
+	Decrypted                     // with the hash block and data decrypted
+)
+
+// A Sector is a 32 KiB sector of a Wii partition, split into its hash block
+// and data.
+type Sector struct {
+	Hashes wii.HashBlock
+	Data   [sectorDataSize]byte
+}
+
+// Bytes returns the sector as it appears on the disc, the hash block
+// followed by the data.
+func (s *Sector) Bytes() []byte {
+	b := make([]byte, 0, util.SectorSize)
+
+	return append(append(b, s.Hashes[:]...), s.Data[:]...)
+}
+
+// H0 returns the hashes of each 1 KiB block of data in the sector. It is
+// only meaningful for a decrypted sector.
+func (s *Sector) H0() [wii.BlocksPerSector][sha1.Size]byte {
+	return s.Hashes.H0()
+}
+
+// H1 returns the hashes of the H0 tables of the eight sectors in the
+// subgroup. It is only meaningful for a decrypted sector.
+func (s *Sector) H1() [wii.SubGroupSize][sha1.Size]byte {
+	return s.Hashes.H1()
+}
+
+// H2 returns the hashes of the H1 tables of the eight subgroups in the
+// cluster. It is only meaningful for a decrypted sector.
+func (s *Sector) H2() [wii.SubGroupSize][sha1.Size]byte {
+	return s.Hashes.H2()
+}
+
+// findSector returns the partition data entry holding sector n of
+// partition p and the sector's index within it.
+func (r *Reader) findSector(p, n int) (int, int, error) {
+	if p >= 0 && p < len(r.part) && n >= 0 {
+		first := r.part[p].Data[0].FirstSector
+
+		for d, x := range r.part[p].Data {
+			if s := int64(first) + int64(n) - int64(x.FirstSector); s >= 0 && s < int64(x.NumSector) {
+				return d, int(s), nil
+			}
+		}
+	}
+
+	return 0, 0, fmt.Errorf("%w: partition %d sector %d", ErrSectorIndex, p, n)
+}
+
+// ReadSector returns sector n of the data of partition p, counting from the
+// start of the partition data, in the given format. Only the cluster
+// holding the sector is rebuilt.
+func (r *Reader) ReadSector(p, n int, format SectorFormat) (*Sector, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+
+	if format != Encrypted && format != Decrypted {
+		return nil, fmt.Errorf("%w: %d", ErrSectorFormat, format)
+	}
+
+	d, s, err := r.findSector(p, n)
+	if err != nil {
+		return nil, err
+	}
+
+	pr := newPartReader(r, p, d)
+	pr.sector = s - s%clusters
+
+	ws, err := pr.build(r.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.pool.put(ws)
+
+	s %= clusters
+	sector := new(Sector)
+
+	switch format {
+	case Encrypted:
+		b := make([]byte, util.SectorSize)
+		pr.encryptSector(ws, s, b)
+
+		copy(sector.Hashes[:], b)
+		copy(sector.Data[:], b[hashSize:])
+	case Decrypted:
+		sector.Hashes = ws.Hashes[s]
+		sector.Data = ws.Data[s]
+	}
+
+	return sector, nil
+}
+
+// A PartitionReader reads the decrypted data of a Wii partition, without
+// the hash blocks, as returned by OpenPartition. Each cluster is rebuilt
+// when it is first needed and the last one is kept, so reading in order only
+// rebuilds each cluster once.
+type PartitionReader struct {
+	pd     partitionData
+	size   int64
+	offset int64
+}
+
+// OpenPartition returns a PartitionReader for the decrypted data of
+// partition p, counting partitions as ReadSector does. It must be closed to
+// release the cluster it holds.
+func (r *Reader) OpenPartition(p int) (*PartitionReader, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+
+	if p < 0 || p >= len(r.part) {
+		return nil, fmt.Errorf("%w: partition %d", ErrSectorIndex, p)
+	}
+
+	var sectors int64
+
+	first := int64(r.part[p].Data[0].FirstSector)
+
+	for _, x := range r.part[p].Data {
+		if end := int64(x.FirstSector) + int64(x.NumSector) - first; x.NumSector > 0 && end > sectors {
+			sectors = end
+		}
+	}
+
+	return &PartitionReader{pd: partitionData{r: r, p: p}, size: sectors * sectorDataSize}, nil
+}
+
+// Size returns the size of the decrypted partition data.
+func (pr *PartitionReader) Size() int64 {
+	return pr.size
+}
+
+// ReadAt reads len(b) bytes of the decrypted partition data from offset.
+func (pr *PartitionReader) ReadAt(b []byte, offset int64) (n int, err error) {
+	if err = pr.pd.r.err(); err != nil {
+		return 0, err
+	}
+
+	if offset < 0 {
+		return 0, ErrOffset
+	}
+
+	if offset >= pr.size {
+		return 0, io.EOF
+	}
+
+	if left := pr.size - offset; int64(len(b)) > left {
+		b = b[:left]
+		err = io.EOF
+	}
+
+	n, rerr := pr.pd.ReadAt(b, offset)
+	if rerr != nil {
+		return n, rerr
+	}
+
+	return n, err
+}
+
+// Read reads the decrypted partition data in order.
+func (pr *PartitionReader) Read(b []byte) (int, error) {
+	n, err := pr.ReadAt(b, pr.offset)
+	pr.offset += int64(n)
+
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Close releases the cluster held by the PartitionReader.
+func (pr *PartitionReader) Close() error {
+	pr.pd.close()
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package rvz_test
+
+import (
+	"bytes"
+	"crypto/sha1" //nolint:gosec
+	"io"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/bodgit/rvz/junk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSector(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+	dataStart := int(tp.firstSector) * sectorSize
+
+	r, err := rvz.NewReader(bytes.NewReader((&testImage{
+		method:     methodZstd,
+		chunkSize:  sectorSize,
+		iso:        iso,
+		partitions: []testPartition{tp},
+	}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{0, 1, 63, 64, 70, 139} {
+		enc, err := r.ReadSector(0, n, rvz.Encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, bytes.Equal(iso[dataStart+n*sectorSize:dataStart+(n+1)*sectorSize], enc.Bytes()))
+
+		dec, err := r.ReadSector(0, n, rvz.Decrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, bytes.Equal(tp.data[n*sectorDataSize:(n+1)*sectorDataSize], dec.Data[:]))
+
+		h0 := dec.H0()
+		assert.Equal(t, sha1.Sum(dec.Data[:0x400]), h0[0])                        //nolint:gosec
+		assert.Equal(t, sha1.Sum(dec.Hashes[:0x26c]), dec.H1()[n%8])              //nolint:gosec
+		assert.Equal(t, sha1.Sum(dec.Hashes[0x280:0x280+0xa0]), dec.H2()[n%64/8]) //nolint:gosec
+	}
+
+	_, err = r.ReadSector(0, 140, rvz.Decrypted)
+	assert.ErrorIs(t, err, rvz.ErrSectorIndex)
+
+	_, err = r.ReadSector(1, 0, rvz.Decrypted)
+	assert.ErrorIs(t, err, rvz.ErrSectorIndex)
+
+	for _, format := range []rvz.SectorFormat{-1, rvz.Decrypted + 1} {
+		_, err = r.ReadSector(0, 0, format)
+		assert.ErrorIs(t, err, rvz.ErrSectorFormat)
+	}
+}
+
+//nolint:funlen
+func TestOpenPartition(t *testing.T) {
+	t.Parallel()
+
+	// Fill part of the partition with the junk data for the game ID in
+	// its own header, counting from the start of the decrypted data
+	data := make([]byte, 140*sectorDataSize)
+	copy(data, "RMCE01")
+
+	d := junk.Disc{ID: [4]byte{'R', 'M', 'C', 'E'}}
+	if _, err := io.ReadFull(d.NewReader(0x20000), data[0x20000:0x60000]); err != nil {
+		t.Fatal(err)
+	}
+
+	iso, tp := testWiiImage(t, data)
+
+	r, err := rvz.NewReader(bytes.NewReader((&testImage{
+		method:     methodZstd,
+		chunkSize:  sectorSize,
+		iso:        iso,
+		partitions: []testPartition{tp},
+	}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.OpenPartition(1)
+	assert.ErrorIs(t, err, rvz.ErrSectorIndex)
+
+	pr, err := r.OpenPartition(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+
+	assert.Equal(t, int64(len(data)), pr.Size())
+
+	// Read across the end of a cluster
+	b := make([]byte, 0x100)
+	n, err := pr.ReadAt(b, 64*sectorDataSize-0x80)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+	assert.Equal(t, data[64*sectorDataSize-0x80:64*sectorDataSize+0x80], b)
+
+	n, err = pr.ReadAt(b, pr.Size()-0x80)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0x80, n)
+
+	_, err = pr.ReadAt(b, -1)
+	assert.ErrorIs(t, err, rvz.ErrOffset)
+
+	got, err := io.ReadAll(pr)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(data, got))
+
+	m, err := junk.Scan(bytes.NewReader(got), d, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []junk.Range{{Offset: 0x20000, Size: 0x40000}}, m.Ranges)
+}
@@ -0,0 +1,127 @@
+package rvz
+
+import (
+	"bytes"
+	"io"
+)
+
+// sparseBlockSize is the size of the blocks checked for zeroes, which
+// matches the block size of most filesystems.
+const sparseBlockSize = 4096
+
+//nolint:gochecknoglobals
+var zeroBlock [sparseBlockSize]byte
+
+// A SparseWriter writes to an io.WriteSeeker, seeking over any aligned
+// 4 KiB blocks that are all zeroes rather than writing them. On filesystems
+// that support them this creates a sparse file, so runs of zeroes such as
+// all-zero groups and scrubbed sectors take up no space on disk. Writes are
+// buffered up to the next block boundary so Flush must be called once
+// everything has been written.
+type SparseWriter struct {
+	w      io.WriteSeeker
+	offset int64 // how much has been written or skipped
+	pos    int64 // the position of w
+	buf    [sparseBlockSize]byte
+	n      int // how much of buf is used
+}
+
+// NewSparseWriter returns a new SparseWriter writing to w, which must be
+// positioned at the start.
+func NewSparseWriter(w io.WriteSeeker) *SparseWriter {
+	return &SparseWriter{w: w}
+}
+
+func (sw *SparseWriter) write(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+
+	if sw.pos != sw.offset {
+		if _, err := sw.w.Seek(sw.offset, io.SeekStart); err != nil {
+			return err
+		}
+
+		sw.pos = sw.offset
+	}
+
+	n, err := sw.w.Write(p)
+	sw.pos += int64(n)
+	sw.offset += int64(n)
+
+	return err
+}
+
+// writeBlocks writes p, which holds whole blocks, skipping any that are all
+// zeroes.
+func (sw *SparseWriter) writeBlocks(p []byte) error {
+	data := 0 // start of data not yet written
+
+	for i := 0; i < len(p); i += sparseBlockSize {
+		if !bytes.Equal(p[i:i+sparseBlockSize], zeroBlock[:]) {
+			continue
+		}
+
+		if err := sw.write(p[data:i]); err != nil {
+			return err
+		}
+
+		sw.offset += sparseBlockSize
+		data = i + sparseBlockSize
+	}
+
+	return sw.write(p[data:])
+}
+
+func (sw *SparseWriter) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if sw.n > 0 {
+		m := copy(sw.buf[sw.n:], p)
+		sw.n += m
+		p = p[m:]
+
+		if sw.n < sparseBlockSize {
+			return n, nil
+		}
+
+		sw.n = 0
+
+		if err := sw.writeBlocks(sw.buf[:]); err != nil {
+			return 0, err
+		}
+	}
+
+	whole := len(p) - len(p)%sparseBlockSize
+	if err := sw.writeBlocks(p[:whole]); err != nil {
+		return 0, err
+	}
+
+	sw.n = copy(sw.buf[:], p[whole:])
+
+	return n, nil
+}
+
+// Flush writes any partial block still buffered and makes sure the size of
+// the underlying file covers everything that has been written, as it won't
+// if that ended with a run of zeroes.
+func (sw *SparseWriter) Flush() error {
+	if err := sw.write(sw.buf[:sw.n]); err != nil {
+		return err
+	}
+
+	sw.n = 0
+
+	if sw.pos == sw.offset {
+		return nil
+	}
+
+	if t, ok := sw.w.(interface{ Truncate(size int64) error }); ok {
+		return t.Truncate(sw.offset)
+	}
+
+	// Write the last zero byte instead
+	sw.offset--
+
+	return sw.write(zeroBlock[:1])
+}
@@ -0,0 +1,126 @@
+package rvz_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+var errSeek = errors.New("unsupported whence")
+
+// seekBuffer is an in-memory io.WriteSeeker that counts the bytes written.
+type seekBuffer struct {
+	b       []byte
+	pos     int64
+	written int
+}
+
+func (sb *seekBuffer) Write(p []byte) (int, error) {
+	if end := int(sb.pos) + len(p); end > len(sb.b) {
+		sb.b = append(sb.b, make([]byte, end-len(sb.b))...)
+	}
+
+	copy(sb.b[sb.pos:], p)
+	sb.pos += int64(len(p))
+	sb.written += len(p)
+
+	return len(p), nil
+}
+
+func (sb *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, errSeek
+	}
+
+	sb.pos = offset
+
+	return offset, nil
+}
+
+type truncateBuffer struct {
+	seekBuffer
+}
+
+func (tb *truncateBuffer) Truncate(size int64) error {
+	if n := int(size) - len(tb.b); n > 0 {
+		tb.b = append(tb.b, make([]byte, n)...)
+	}
+
+	tb.b = tb.b[:size]
+
+	return nil
+}
+
+func TestSparseWriter(t *testing.T) {
+	t.Parallel()
+
+	iso := testISO(20)
+
+	// Finish with a run of zeroes
+	iso = append(iso, make([]byte, 3*sectorSize+0x123)...)
+
+	b := (&testImage{method: methodZstd, iso: iso}).build(t)
+
+	tables := map[string]struct {
+		ws  func() (io.WriteSeeker, *seekBuffer)
+		buf int
+	}{
+		"Truncate": {
+			ws: func() (io.WriteSeeker, *seekBuffer) {
+				tb := new(truncateBuffer)
+
+				return tb, &tb.seekBuffer
+			},
+		},
+		"NoTruncate": {
+			ws: func() (io.WriteSeeker, *seekBuffer) {
+				sb := new(seekBuffer)
+
+				return sb, sb
+			},
+		},
+		"SmallWrites": {
+			ws: func() (io.WriteSeeker, *seekBuffer) {
+				sb := new(seekBuffer)
+
+				return sb, sb
+			},
+			buf: 1000,
+		},
+	}
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := rvz.NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ws, sb := table.ws()
+			sw := rvz.NewSparseWriter(ws)
+
+			if table.buf > 0 {
+				_, err = io.CopyBuffer(sw, struct{ io.Reader }{r}, make([]byte, table.buf))
+			} else {
+				_, err = io.Copy(sw, r)
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.NoError(t, sw.Flush())
+			assert.True(t, bytes.Equal(iso, sb.b))
+
+			// Every fourth sector of testISO is all zeroes
+			assert.Less(t, sb.written, len(iso)-5*sectorSize)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package rvz
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/rvz/internal/util"
+)
+
+// FAT32SplitSize is the largest size of a part that fits on a FAT32
+// filesystem while still ending on a sector boundary, 4 GiB - 32 KiB.
+const FAT32SplitSize = 1<<32 - util.SectorSize
+
+// SplitNaming is a scheme for naming the parts of a split disc image.
+type SplitNaming int
+
+// The supported naming schemes, shown for a disc image named image.iso.
+const (
+	SplitPart   SplitNaming = iota // image.part0.iso, image.part1.iso, ...
+	SplitSuffix                    // image.iso, image.iso.1, image.iso.2, ...
+)
+
+// Name returns the name of part i of the disc image name.
+func (n SplitNaming) Name(name string, i int) string {
+	if n == SplitSuffix {
+		if i == 0 {
+			return name
+		}
+
+		return fmt.Sprintf("%s.%d", name, i)
+	}
+
+	ext := filepath.Ext(name)
+
+	return fmt.Sprintf("%s.part%d%s", strings.TrimSuffix(name, ext), i, ext)
+}
+
+// A SplitWriter splits everything written to it into parts of a fixed
+// size, which is a multiple of the sector size so that no sector is split
+// between two parts. Each part is only created once there is something to
+// write to it.
+type SplitWriter struct {
+	size   int64
+	create func(int) (io.WriteCloser, error)
+	w      io.WriteCloser
+	n      int64 // written to the current part
+	parts  int
+}
+
+// NewSplitWriter returns a new SplitWriter that writes parts of size bytes
+// to the io.WriteCloser returned by create for each part in turn.
+func NewSplitWriter(size int64, create func(part int) (io.WriteCloser, error)) (*SplitWriter, error) {
+	if size <= 0 || size%util.SectorSize != 0 {
+		return nil, ErrSplitSize
+	}
+
+	return &SplitWriter{size: size, create: create}, nil
+}
+
+// CreateSplit returns a new SplitWriter that writes parts of size bytes to
+// files named from name using naming.
+func CreateSplit(name string, size int64, naming SplitNaming) (*SplitWriter, error) {
+	return NewSplitWriter(size, func(i int) (io.WriteCloser, error) {
+		return os.Create(naming.Name(name, i))
+	})
+}
+
+func (sw *SplitWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if sw.w == nil {
+			if sw.w, err = sw.create(sw.parts); err != nil {
+				return
+			}
+
+			sw.n = 0
+			sw.parts++
+		}
+
+		m := len(p)
+		if remaining := sw.size - sw.n; int64(m) > remaining {
+			m = int(remaining)
+		}
+
+		m, err = sw.w.Write(p[:m])
+		n += m
+		sw.n += int64(m)
+		p = p[m:]
+
+		if err != nil {
+			return
+		}
+
+		if sw.n == sw.size {
+			err = sw.w.Close()
+			sw.w = nil
+
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// Parts returns how many parts have been created.
+func (sw *SplitWriter) Parts() int {
+	return sw.parts
+}
+
+// Close closes the current part, if any.
+func (sw *SplitWriter) Close() (err error) {
+	if sw.w != nil {
+		err = sw.w.Close()
+		sw.w = nil
+	}
+
+	return
+}
@@ -0,0 +1,76 @@
+package rvz_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (bc *bufferCloser) Close() error {
+	bc.closed = true
+
+	return nil
+}
+
+func TestSplitWriter(t *testing.T) {
+	t.Parallel()
+
+	iso := testISO(20)
+
+	r, err := rvz.NewReader(bytes.NewReader((&testImage{method: methodZstd, iso: iso}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parts []*bufferCloser
+
+	sw, err := rvz.NewSplitWriter(3*sectorSize, func(i int) (io.WriteCloser, error) {
+		assert.Equal(t, len(parts), i)
+
+		parts = append(parts, new(bufferCloser))
+
+		return parts[i], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = io.Copy(sw, r); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, sw.Close())
+	assert.Equal(t, 7, sw.Parts())
+
+	for i, part := range parts {
+		end := (i + 1) * 3 * sectorSize
+		if end > len(iso) {
+			end = len(iso)
+		}
+
+		assert.True(t, part.closed)
+		assert.True(t, bytes.Equal(iso[i*3*sectorSize:end], part.Bytes()))
+	}
+
+	for _, size := range []int64{0, -sectorSize, sectorSize + 1} {
+		_, err = rvz.NewSplitWriter(size, nil)
+		assert.ErrorIs(t, err, rvz.ErrSplitSize)
+	}
+}
+
+func TestSplitNaming(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "dir/image.part0.iso", rvz.SplitPart.Name("dir/image.iso", 0))
+	assert.Equal(t, "dir/image.part2.iso", rvz.SplitPart.Name("dir/image.iso", 2))
+	assert.Equal(t, "image.iso", rvz.SplitSuffix.Name("image.iso", 0))
+	assert.Equal(t, "image.iso.1", rvz.SplitSuffix.Name("image.iso", 1))
+}
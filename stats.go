@@ -0,0 +1,169 @@
+package rvz
+
+import (
+	"github.com/bodgit/rvz/internal/packed"
+	"github.com/bodgit/rvz/internal/util"
+)
+
+// RatioBuckets is the number of buckets in the histogram of compression
+// ratios. Each covers 10%, apart from the last which holds every group that
+// takes up at least as much space as the data it decodes to.
+const RatioBuckets = 11
+
+// GroupStats summarises a set of groups.
+type GroupStats struct {
+	// Groups is the number of groups.
+	Groups int
+	// Zero is the number of groups that are all zeroes and take up no
+	// space in the image.
+	Zero int
+	// Stored is the number of groups stored without compression.
+	Stored int
+	// Compressed is the number of compressed groups.
+	Compressed int
+	// Packed is the number of groups that use RVZ packing, which can also
+	// be stored or compressed.
+	Packed int
+	// FileSize is how many bytes the groups take up in the image.
+	FileSize int64
+	// Size is how many bytes the groups decode to, not counting the hashes
+	// of Wii partition data.
+	Size int64
+	// Junk is how many of the decoded bytes are junk data regenerated
+	// from padding seeds.
+	Junk int64
+	// Ratios is a histogram of the compression ratio of each group, that
+	// is its FileSize divided by its Size.
+	Ratios [RatioBuckets]int
+}
+
+func (gs *GroupStats) add(o *GroupStats) {
+	gs.Groups += o.Groups
+	gs.Zero += o.Zero
+	gs.Stored += o.Stored
+	gs.Compressed += o.Compressed
+	gs.Packed += o.Packed
+	gs.FileSize += o.FileSize
+	gs.Size += o.Size
+	gs.Junk += o.Junk
+
+	for i := range gs.Ratios {
+		gs.Ratios[i] += o.Ratios[i]
+	}
+}
+
+// RegionStats holds the statistics of the groups starting in a Region.
+type RegionStats struct {
+	Region Region
+	GroupStats
+}
+
+// Stats holds the statistics of every region of the disc image and their
+// total.
+type Stats struct {
+	Regions []RegionStats
+	Total   GroupStats
+}
+
+// groupSize returns how many bytes group g starting at start in rg decodes
+// to.
+func (r *Reader) groupSize(rg region, start int64) int64 {
+	end := start + r.disc.chunkSize(false)
+	if rgEnd := rg.offset + rg.size; end > rgEnd {
+		end = rgEnd
+	}
+
+	if rg.raw < 0 {
+		return (end - start) / util.SectorSize * sectorDataSize
+	}
+
+	return end - start
+}
+
+// junk returns how much of packed group g is junk data.
+func (r *Reader) junk(g int, partition bool) (int64, error) {
+	rc, err := r.groupData(g, partition)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	return packed.Junk(rc)
+}
+
+func (r *Reader) addGroup(gs *GroupStats, rg region, g int, start int64) error {
+	group := &r.group[g]
+	size := r.groupSize(rg, start)
+
+	gs.Groups++
+	gs.FileSize += group.size()
+	gs.Size += size
+
+	switch {
+	case group.size() == 0:
+		gs.Zero++
+	case group.compressed():
+		gs.Compressed++
+	default:
+		gs.Stored++
+	}
+
+	if group.PackedSize != 0 {
+		gs.Packed++
+
+		junk, err := r.junk(g, rg.raw < 0)
+		if err != nil {
+			return r.decodeError(err, start, g, rg.partition())
+		}
+
+		gs.Junk += junk
+	}
+
+	bucket := RatioBuckets - 1
+	if size > 0 {
+		if b := int(group.size() * (RatioBuckets - 1) / size); b < bucket {
+			bucket = b
+		}
+	}
+
+	gs.Ratios[bucket]++
+
+	return nil
+}
+
+// Stats walks the group table and summarises the groups in each region of
+// the disc image, as returned by Layout. Packed groups are decompressed to
+// count how much junk data they hold.
+func (r *Reader) Stats() (*Stats, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+
+	layout, parents, err := r.layout()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		Regions: make([]RegionStats, len(layout)),
+	}
+
+	for i, region := range layout {
+		rs := &stats.Regions[i]
+		rs.Region = region
+
+		r.ownGroups(parents[i], region, func(g int, start int64) {
+			if err == nil {
+				err = r.addGroup(&rs.GroupStats, parents[i], g, start)
+			}
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		stats.Total.add(&rs.GroupStats)
+	}
+
+	return stats, nil
+}
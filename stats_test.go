@@ -0,0 +1,74 @@
+package rvz_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+//nolint:funlen
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	iso := testISO(20)
+	seeds := testJunk(t, iso, 1, 5)
+
+	r, err := rvz.NewReader(bytes.NewReader((&testImage{
+		method:    methodZstd,
+		chunkSize: sectorSize,
+		iso:       iso,
+		seeds:     seeds,
+	}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := r.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, stats.Regions, 1) {
+		assert.Equal(t, stats.Total, stats.Regions[0].GroupStats)
+		assert.Equal(t, rvz.RawData, stats.Regions[0].Region.Kind)
+	}
+
+	total := stats.Total
+	assert.Equal(t, 20, total.Groups)
+	assert.Equal(t, total.Groups, total.Zero+total.Stored+total.Compressed)
+	assert.Equal(t, 5, total.Zero) // every fourth sector
+	assert.Equal(t, total.Groups-total.Zero, total.Packed)
+	assert.Equal(t, int64(len(iso)), total.Size)
+	assert.Equal(t, int64(2*sectorSize), total.Junk)
+	assert.Less(t, total.FileSize, total.Size)
+
+	var n int
+	for _, x := range total.Ratios {
+		n += x
+	}
+
+	assert.Equal(t, total.Groups, n)
+	assert.GreaterOrEqual(t, total.Ratios[0], total.Zero)
+
+	iso, tp := testWiiISO(t, 140)
+
+	r, err = rvz.NewReader(bytes.NewReader((&testImage{
+		method:     methodZstd,
+		iso:        iso,
+		partitions: []testPartition{tp},
+	}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats, err = r.Stats(); err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, stats.Regions, 4) {
+		assert.Equal(t, int64(140*sectorDataSize), stats.Regions[2].Size)
+		assert.Equal(t, 16, stats.Regions[2].Zero) // the second cluster
+	}
+}
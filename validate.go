@@ -0,0 +1,96 @@
+package rvz
+
+import (
+	"fmt"
+
+	"github.com/bodgit/rvz/internal/util"
+)
+
+// maxIsoFileSize is comfortably more than the largest dual-layer Wii disc.
+const maxIsoFileSize = 1 << 34 // 16 GiB
+
+func (r *Reader) isoSectors() uint64 {
+	return (r.header.IsoFileSize + util.SectorSize - 1) / util.SectorSize
+}
+
+// inFile reports whether size bytes at offset lie within the RVZ file.
+func (r *Reader) inFile(offset, size uint64) bool {
+	return offset <= r.header.RvzFileSize && size <= r.header.RvzFileSize-offset
+}
+
+// inGroups reports whether the n groups starting at index exist and are
+// enough to hold size bytes in chunks of chunkSize bytes.
+func (r *Reader) inGroups(index, n uint32, size, chunkSize uint64) bool {
+	return uint64(index)+uint64(n) <= uint64(r.disc.NumGroup) && uint64(n)*chunkSize >= size
+}
+
+// checkTables bounds the table sizes taken from the disc struct before
+// anything is allocated based on them.
+func (r *Reader) checkTables() error {
+	if r.header.IsoFileSize == 0 || r.header.IsoFileSize > maxIsoFileSize {
+		return ErrImageSize
+	}
+
+	if int(r.disc.ComprDataLen) > len(r.disc.ComprData) {
+		return ErrComprData
+	}
+
+	// Every partition needs at least one sector, and so does every raw
+	// data region except possibly the one at the start of the disc
+	sectors := r.isoSectors()
+	if uint64(r.disc.NumPart) > sectors || uint64(r.disc.NumRawData) > sectors+1 {
+		return ErrTableSize
+	}
+
+	// Each group covers a whole chunk, except the last one in each region
+	regions := uint64(r.disc.NumRawData) + uint64(len(part{}.Data))*uint64(r.disc.NumPart)
+	if uint64(r.disc.NumGroup) > sectors/uint64(r.disc.sectorsPerChunk())+regions {
+		return ErrTableSize
+	}
+
+	for _, t := range []struct {
+		offset, size uint64
+	}{
+		{r.disc.PartOff, uint64(r.disc.NumPart) * uint64(r.disc.PartSize)},
+		{r.disc.RawDataOff, uint64(r.disc.RawDataSize)},
+		{r.disc.GroupOff, uint64(r.disc.GroupSize)},
+	} {
+		if !r.inFile(t.offset, t.size) {
+			return ErrTableBounds
+		}
+	}
+
+	return nil
+}
+
+// checkEntries cross-validates the raw data, partition and group entries
+// against each other and the sizes in the header.
+func (r *Reader) checkEntries() error {
+	chunkSize := uint64(r.disc.ChunkSize)
+
+	for i, x := range r.raw {
+		if x.RawDataSize > r.header.IsoFileSize || x.RawDataOff > r.header.IsoFileSize-x.RawDataSize ||
+			!r.inGroups(x.GroupIndex, x.NumGroup, x.RawDataSize, chunkSize) {
+			return fmt.Errorf("%w %d", ErrRawEntry, i)
+		}
+	}
+
+	sectors := r.isoSectors()
+
+	for i, x := range r.part {
+		for _, d := range x.Data {
+			if uint64(d.FirstSector)+uint64(d.NumSector) > sectors ||
+				!r.inGroups(d.GroupIndex, d.NumGroup, uint64(d.NumSector), uint64(r.disc.sectorsPerChunk())) {
+				return fmt.Errorf("%w %d", ErrPartEntry, i)
+			}
+		}
+	}
+
+	for i, g := range r.group {
+		if g.size() > 0 && !r.inFile(uint64(g.offset()), uint64(g.size())) {
+			return fmt.Errorf("%w %d", ErrGroupEntry, i)
+		}
+	}
+
+	return nil
+}
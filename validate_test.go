@@ -0,0 +1,76 @@
+package rvz_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHardening(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name    string
+		mangle  func([]testGroup)
+		corrupt func([]byte)
+		target  error
+	}{
+		{
+			name: "NumGroup",
+			corrupt: func(b []byte) {
+				binary.BigEndian.PutUint32(b[discNumGroup:], math.MaxUint32)
+			},
+			target: rvz.ErrTableSize,
+		},
+		{
+			name: "NumPart",
+			corrupt: func(b []byte) {
+				binary.BigEndian.PutUint32(b[discNumPart:], math.MaxUint32)
+			},
+			target: rvz.ErrTableSize,
+		},
+		{
+			name: "GroupOff",
+			corrupt: func(b []byte) {
+				binary.BigEndian.PutUint64(b[discGroupOff:], math.MaxUint64)
+			},
+			target: rvz.ErrTableBounds,
+		},
+		{
+			name: "ComprDataLen",
+			corrupt: func(b []byte) {
+				b[discComprDataLen] = 0xff
+			},
+			target: rvz.ErrComprData,
+		},
+		{
+			name: "GroupOffset",
+			mangle: func(groups []testGroup) {
+				groups[1].Offset = math.MaxUint32
+			},
+			target: rvz.ErrGroupEntry,
+		},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := (&testImage{iso: testISO(16), mangle: table.mangle}).build(t)
+			if table.corrupt != nil {
+				table.corrupt(b)
+				rehash(b)
+			}
+
+			_, err := rvz.NewReader(bytes.NewReader(b))
+			assert.ErrorIs(t, err, table.target)
+			assert.ErrorIs(t, err, rvz.ErrCorrupt)
+		})
+	}
+}
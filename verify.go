@@ -0,0 +1,162 @@
+package rvz
+
+import (
+	"bytes"
+	"crypto/sha1" //nolint:gosec
+	"fmt"
+
+	"github.com/bodgit/rvz/internal/util"
+	"github.com/bodgit/rvz/wii"
+)
+
+// HashMismatch identifies part of a Wii partition whose contents don't match
+// the hashes recorded on the disc.
+type HashMismatch struct {
+	// Partition is the index of the partition.
+	Partition int
+	// Cluster is the index of the 2 MiB cluster within the partition
+	// data whose H2 hashes don't match the H3 table, or -1 if the H3 table
+	// itself doesn't match the content hash in the TMD.
+	Cluster int
+	// Offset is the offset of the cluster, or of the H3 table, in the
+	// disc image.
+	Offset int64
+}
+
+func (r *Reader) firstSector(p int) int64 {
+	for _, d := range r.part[p].Data {
+		if d.NumSector > 0 {
+			return int64(d.FirstSector)
+		}
+	}
+
+	return 0
+}
+
+// partition finds the partition holding the data for partition entry p.
+func (r *Reader) partition(p int) (*Partition, error) {
+	partitions, err := r.Partitions()
+	if err != nil {
+		return nil, err
+	}
+
+	start := r.firstSector(p) * util.SectorSize
+
+	for i := range partitions {
+		if partitions[i].Offset+partitions[i].Header.DataOffset == start {
+			return &partitions[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w %d", ErrNoPartition, p)
+}
+
+//nolint:cyclop
+func (r *Reader) verifyPartition(p int) ([]HashMismatch, error) {
+	partition, err := r.partition(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(partition.Header.TMD.Contents) == 0 {
+		return nil, fmt.Errorf("%w %d", ErrTMDSize, p)
+	}
+
+	h3Offset := partition.Offset + partition.Header.H3Offset
+
+	h3 := make([]byte, wii.H3Size)
+	if _, err = r.readAt(h3, h3Offset); err != nil {
+		return nil, err
+	}
+
+	var mismatches []HashMismatch
+
+	if sum := sha1.Sum(h3); partition.Header.TMD.Contents[0].Hash != sum { //nolint:gosec
+		mismatches = append(mismatches, HashMismatch{
+			Partition: p,
+			Cluster:   -1,
+			Offset:    h3Offset,
+		})
+	}
+
+	first := r.firstSector(p)
+
+	for d, x := range r.part[p].Data {
+		if x.NumSector == 0 {
+			continue
+		}
+
+		pr := newPartReader(r, p, d)
+
+		for ; pr.sector < int(x.NumSector); pr.sector += clusters {
+			ws, err := pr.build(r.ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			// Every sector in the cluster carries the same H2 hashes
+			sum := ws.H3()
+			r.pool.put(ws)
+			cluster := (int(int64(x.FirstSector)-first) + pr.sector) / clusters
+
+			if (cluster+1)*sha1.Size > wii.H3Size || !bytes.Equal(h3[cluster*sha1.Size:(cluster+1)*sha1.Size], sum[:]) {
+				mismatches = append(mismatches, HashMismatch{
+					Partition: p,
+					Cluster:   cluster,
+					Offset:    (int64(x.FirstSector) + int64(pr.sector)) * util.SectorSize,
+				})
+			}
+		}
+	}
+
+	return mismatches, nil
+}
+
+// VerifyPartitions recalculates the hashes of every Wii partition and checks
+// them against the H3 table and the content hash in the TMD, returning any
+// mismatches found. A GameCube disc image has no partitions to verify.
+func (r *Reader) VerifyPartitions() ([]HashMismatch, error) {
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+
+	var mismatches []HashMismatch
+
+	for p := range r.part {
+		m, err := r.verifyPartition(p)
+		if err != nil {
+			return nil, err
+		}
+
+		mismatches = append(mismatches, m...)
+	}
+
+	return mismatches, nil
+}
+
+// VerifyTitleKeys decrypts the title key in the ticket of every Wii partition
+// using keys and checks it matches the decrypted key stored in the image,
+// which is used to re-encrypt the partition data.
+func (r *Reader) VerifyTitleKeys(keys wii.CommonKeys) error {
+	if err := r.err(); err != nil {
+		return err
+	}
+
+	for p := range r.part {
+		partition, err := r.partition(p)
+		if err != nil {
+			return err
+		}
+
+		key, err := partition.Header.Ticket.DecryptTitleKey(keys)
+		if err != nil {
+			return err
+		}
+
+		if key != r.part[p].Key {
+			return fmt.Errorf("%w %d", ErrTitleKey, p)
+		}
+	}
+
+	return nil
+}
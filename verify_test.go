@@ -0,0 +1,88 @@
+package rvz_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bodgit/rvz"
+	"github.com/bodgit/rvz/wii"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyPartitions(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+
+	// Change the data in the third cluster after the hashes were made
+	data := append([]byte(nil), tp.data...)
+	data[130*sectorDataSize] ^= 0xff
+
+	b := (&testImage{
+		iso:        iso,
+		partitions: []testPartition{{key: tp.key, firstSector: tp.firstSector, data: data}},
+	}).build(t)
+
+	r, err := rvz.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err := r.VerifyPartitions()
+	assert.NoError(t, err)
+	assert.Equal(t, []rvz.HashMismatch{
+		{
+			Partition: 0,
+			Cluster:   2,
+			Offset:    int64(tp.firstSector+128) * sectorSize,
+		},
+	}, mismatches)
+
+	// Change the H3 table so it no longer matches the TMD
+	iso = append([]byte(nil), iso...)
+	iso[testPartOffset+testH3Offset+0x1000] ^= 0xff
+
+	b = (&testImage{iso: iso, partitions: []testPartition{tp}}).build(t)
+
+	r, err = rvz.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err = r.VerifyPartitions()
+	assert.NoError(t, err)
+	assert.Equal(t, []rvz.HashMismatch{
+		{
+			Partition: 0,
+			Cluster:   -1,
+			Offset:    testPartOffset + testH3Offset,
+		},
+	}, mismatches)
+}
+
+func TestVerifyTitleKeys(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 70)
+
+	r, err := rvz.NewReader(bytes.NewReader((&testImage{iso: iso, partitions: []testPartition{tp}}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, r.VerifyTitleKeys(wii.CommonKeys{testCommonKey}))
+	assert.ErrorIs(t, r.VerifyTitleKeys(wii.CommonKeys{{}}), rvz.ErrTitleKey)
+	assert.ErrorIs(t, r.VerifyTitleKeys(nil), wii.ErrNoCommonKey)
+
+	// An image made with the wrong title key
+	tp.key[0] ^= 0xff
+
+	r, err = rvz.NewReader(bytes.NewReader((&testImage{iso: iso, partitions: []testPartition{tp}}).build(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.VerifyTitleKeys(wii.CommonKeys{testCommonKey})
+	assert.ErrorIs(t, err, rvz.ErrTitleKey)
+	assert.ErrorIs(t, err, rvz.ErrCorrupt)
+}
@@ -0,0 +1,289 @@
+package wii
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1" //nolint:gosec
+	"fmt"
+)
+
+// The layout of the sectors in a Wii partition. Each sector starts with a
+// hash block followed by the data, and 64 sectors make a cluster that shares
+// a hash tree.
+const (
+	SectorSize        = 0x8000
+	HashBlockSize     = 0x400
+	SectorDataSize    = SectorSize - HashBlockSize
+	BlockSize         = 0x400 // the data hashed by each H0 hash
+	BlocksPerSector   = SectorDataSize / BlockSize
+	SubGroupSize      = 8 // sectors in a subgroup, and subgroups in a cluster
+	SectorsPerCluster = SubGroupSize * SubGroupSize
+	ClusterSize       = SectorSize * SectorsPerCluster
+
+	H0Offset = 0
+	H0Size   = BlocksPerSector * sha1.Size
+	H1Offset = 0x280
+	H1Size   = SubGroupSize * sha1.Size
+	H2Offset = 0x340
+	H2Size   = SubGroupSize * sha1.Size
+
+	// IVOffset is where the IV used to encrypt the data of a sector is
+	// found in its encrypted hash block.
+	IVOffset = 0x3d0
+
+	// H3Size is the size of the H3 table, which holds the hash of the H2
+	// table of every cluster.
+	H3Size = 0x18000
+)
+
+// A HashError reports a hash in the tree of a cluster that doesn't match.
+type HashError struct {
+	Sector int // the sector within the cluster
+	Level  int // 0 for H0, 1 for H1 and 2 for H2
+}
+
+func (e *HashError) Error() string {
+	return fmt.Sprintf("wii: H%d hash mismatch in sector %d", e.Level, e.Sector)
+}
+
+// Is returns true if target is ErrHash.
+func (e *HashError) Is(target error) bool {
+	return target == ErrHash //nolint:errorlint
+}
+
+// A HashBlock is the decrypted hash block at the start of a sector.
+type HashBlock [HashBlockSize]byte
+
+func (hb *HashBlock) table(offset int, h [][sha1.Size]byte) {
+	for i := range h {
+		copy(h[i][:], hb[offset+i*sha1.Size:])
+	}
+}
+
+// H0 returns the hashes of each block of data in the sector.
+func (hb *HashBlock) H0() (h [BlocksPerSector][sha1.Size]byte) {
+	hb.table(H0Offset, h[:])
+
+	return
+}
+
+// H1 returns the hashes of the H0 tables of the sectors in the subgroup.
+func (hb *HashBlock) H1() (h [SubGroupSize][sha1.Size]byte) {
+	hb.table(H1Offset, h[:])
+
+	return
+}
+
+// H2 returns the hashes of the H1 tables of the subgroups in the cluster.
+func (hb *HashBlock) H2() (h [SubGroupSize][sha1.Size]byte) {
+	hb.table(H2Offset, h[:])
+
+	return
+}
+
+// A Cluster holds the decrypted hash blocks and data of the 64 sectors that
+// share a hash tree. Sectors past the end of a partition are all zeroes.
+type Cluster struct {
+	Hashes [SectorsPerCluster]HashBlock
+	Data   [SectorsPerCluster][SectorDataSize]byte
+}
+
+// HashSector calculates the H0 hashes of sector i from its data, clearing
+// the padding that follows them.
+func (c *Cluster) HashSector(i int) {
+	hb, d := &c.Hashes[i], &c.Data[i]
+
+	for j := 0; j < BlocksPerSector; j++ {
+		sum := sha1.Sum(d[j*BlockSize : (j+1)*BlockSize]) //nolint:gosec
+		copy(hb[H0Offset+j*sha1.Size:], sum[:])
+	}
+
+	for j := H0Offset + H0Size; j < H1Offset; j++ {
+		hb[j] = 0
+	}
+}
+
+// subGroupH1 returns the H1 table of subgroup i.
+func (c *Cluster) subGroupH1(i int) (h1 [H1Size]byte) {
+	for j := 0; j < SubGroupSize; j++ {
+		sum := sha1.Sum(c.Hashes[i*SubGroupSize+j][H0Offset : H0Offset+H0Size]) //nolint:gosec
+		copy(h1[j*sha1.Size:], sum[:])
+	}
+
+	return
+}
+
+// h2 returns the H2 table of the cluster.
+func (c *Cluster) h2() (h2 [H2Size]byte) {
+	for i := 0; i < SubGroupSize; i++ {
+		h1 := c.subGroupH1(i)
+		sum := sha1.Sum(h1[:]) //nolint:gosec
+		copy(h2[i*sha1.Size:], sum[:])
+	}
+
+	return
+}
+
+// HashTree calculates the H1 and H2 hashes from the H0 hashes of every
+// sector and copies them into each hash block, clearing the padding.
+func (c *Cluster) HashTree() {
+	var h2 [H2Size]byte
+
+	for i := 0; i < SubGroupSize; i++ {
+		h1 := c.subGroupH1(i)
+
+		for j := 0; j < SubGroupSize; j++ {
+			hb := &c.Hashes[i*SubGroupSize+j]
+			copy(hb[H1Offset:], h1[:])
+
+			for k := H1Offset + H1Size; k < H2Offset; k++ {
+				hb[k] = 0
+			}
+		}
+
+		sum := sha1.Sum(h1[:]) //nolint:gosec
+		copy(h2[i*sha1.Size:], sum[:])
+	}
+
+	for i := range c.Hashes {
+		hb := &c.Hashes[i]
+		copy(hb[H2Offset:], h2[:])
+
+		for k := H2Offset + H2Size; k < HashBlockSize; k++ {
+			hb[k] = 0
+		}
+	}
+}
+
+// Hash calculates the whole hash tree of the cluster from its data.
+func (c *Cluster) Hash() {
+	for i := range c.Data {
+		c.HashSector(i)
+	}
+
+	c.HashTree()
+}
+
+// H3 returns the hash of the H2 table of the cluster, which is its entry in
+// the H3 table.
+func (c *Cluster) H3() [sha1.Size]byte {
+	return sha1.Sum(c.Hashes[0][H2Offset : H2Offset+H2Size]) //nolint:gosec
+}
+
+// Verify checks every hash in the tree of the cluster against the data,
+// returning a HashError for the first one that doesn't match.
+func (c *Cluster) Verify() error {
+	var h0 HashBlock
+
+	for i := range c.Data {
+		for j := 0; j < BlocksPerSector; j++ {
+			sum := sha1.Sum(c.Data[i][j*BlockSize : (j+1)*BlockSize]) //nolint:gosec
+			copy(h0[j*sha1.Size:], sum[:])
+		}
+
+		if !bytes.Equal(h0[:H0Size], c.Hashes[i][H0Offset:H0Offset+H0Size]) {
+			return &HashError{Sector: i, Level: 0}
+		}
+	}
+
+	h2 := c.h2()
+
+	for i := 0; i < SubGroupSize; i++ {
+		h1 := c.subGroupH1(i)
+
+		for j := 0; j < SubGroupSize; j++ {
+			s := i*SubGroupSize + j
+			if !bytes.Equal(h1[:], c.Hashes[s][H1Offset:H1Offset+H1Size]) {
+				return &HashError{Sector: s, Level: 1}
+			}
+		}
+	}
+
+	for i := range c.Hashes {
+		if !bytes.Equal(h2[:], c.Hashes[i][H2Offset:H2Offset+H2Size]) {
+			return &HashError{Sector: i, Level: 2}
+		}
+	}
+
+	return nil
+}
+
+// EncryptSector encrypts sector i of the cluster into dst, which must be at
+// least SectorSize bytes, using block created from the partition's title
+// key.
+func (c *Cluster) EncryptSector(block cipher.Block, i int, dst []byte) {
+	EncryptSector(block, dst, c.Hashes[i][:], c.Data[i][:])
+}
+
+// DecryptSector decrypts the sector in src, which must be at least
+// SectorSize bytes, into sector i of the cluster using block created from
+// the partition's title key.
+func (c *Cluster) DecryptSector(block cipher.Block, i int, src []byte) {
+	DecryptSector(block, c.Hashes[i][:], c.Data[i][:], src)
+}
+
+//nolint:gochecknoglobals
+var zeroIV [aes.BlockSize]byte
+
+// EncryptSector encrypts the hash block and data of a sector into dst. The
+// hash block is encrypted with AES-CBC using a zero IV, and the data with
+// the IV found at IVOffset in the encrypted hash block. It doesn't allocate.
+func EncryptSector(block cipher.Block, dst, hashes, data []byte) {
+	encryptCBC(block, zeroIV[:], dst[:HashBlockSize], hashes[:HashBlockSize])
+	encryptCBC(block, dst[IVOffset:IVOffset+aes.BlockSize], dst[HashBlockSize:SectorSize], data[:SectorDataSize])
+}
+
+// DecryptSector decrypts the sector in src into its hash block and data. It
+// is the inverse of EncryptSector.
+func DecryptSector(block cipher.Block, hashes, data, src []byte) {
+	decryptCBC(block, zeroIV[:], hashes[:HashBlockSize], src[:HashBlockSize])
+	decryptCBC(block, src[IVOffset:IVOffset+aes.BlockSize], data[:SectorDataSize], src[HashBlockSize:SectorSize])
+}
+
+func encryptCBC(block cipher.Block, iv, dst, src []byte) {
+	prev := iv
+
+	for i := 0; i < len(src); i += aes.BlockSize {
+		d := dst[i : i+aes.BlockSize]
+
+		for j := range d {
+			d[j] = src[i+j] ^ prev[j]
+		}
+
+		block.Encrypt(d, d)
+		prev = d
+	}
+}
+
+func decryptCBC(block cipher.Block, iv, dst, src []byte) {
+	prev := iv
+
+	for i := 0; i < len(src); i += aes.BlockSize {
+		d, s := dst[i:i+aes.BlockSize], src[i:i+aes.BlockSize]
+
+		block.Decrypt(d, s)
+
+		for j := range d {
+			d[j] ^= prev[j]
+		}
+
+		prev = s
+	}
+}
+
+// H3Table returns an H3 table holding the hashes of each cluster returned by
+// their H3 method, padded with zeroes.
+func H3Table(hashes [][sha1.Size]byte) ([]byte, error) {
+	if len(hashes)*sha1.Size > H3Size {
+		return nil, ErrH3Size
+	}
+
+	h3 := make([]byte, H3Size)
+
+	for i, h := range hashes {
+		copy(h3[i*sha1.Size:], h[:])
+	}
+
+	return h3, nil
+}
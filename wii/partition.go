@@ -0,0 +1,90 @@
+package wii
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Limits on the sizes read from a partition header, well beyond anything
+// found on a real disc.
+const (
+	maxTMDSize  = 0x10000
+	maxCertSize = 0x10000
+)
+
+// partitionHeader follows the ticket. Apart from the sizes, everything is
+// shifted right by two.
+type partitionHeader struct {
+	TMDSize  uint32
+	TMDOff   uint32
+	CertSize uint32
+	CertOff  uint32
+	H3Off    uint32
+	DataOff  uint32
+	DataSize uint32
+}
+
+// PartitionHeader is the header at the start of each Wii partition. The
+// offsets are relative to the start of the partition.
+type PartitionHeader struct {
+	Ticket       *Ticket
+	TMD          *TMD
+	Certificates []*Certificate
+	H3Offset     int64
+	DataOffset   int64
+	DataSize     int64
+}
+
+// ReadPartitionHeader reads the header of the partition at offset in the disc
+// image read from r.
+func ReadPartitionHeader(r io.ReaderAt, offset int64) (*PartitionHeader, error) {
+	b := make([]byte, TicketSize+binary.Size(partitionHeader{}))
+	if _, err := r.ReadAt(b, offset); err != nil {
+		return nil, err
+	}
+
+	ticket, err := ParseTicket(b[:TicketSize])
+	if err != nil {
+		return nil, err
+	}
+
+	ph := new(partitionHeader)
+	if err = binary.Read(bytes.NewReader(b[TicketSize:]), binary.BigEndian, ph); err != nil {
+		return nil, err
+	}
+
+	if ph.TMDSize > maxTMDSize || ph.CertSize > maxCertSize {
+		return nil, fmt.Errorf("%w: TMD or certificate chain too large", ErrPartitionHeader)
+	}
+
+	b = make([]byte, ph.TMDSize)
+	if _, err = r.ReadAt(b, offset+int64(ph.TMDOff)<<2); err != nil {
+		return nil, err
+	}
+
+	tmd, err := ParseTMD(b)
+	if err != nil {
+		return nil, err
+	}
+
+	b = make([]byte, ph.CertSize)
+	if _, err = r.ReadAt(b, offset+int64(ph.CertOff)<<2); err != nil {
+		return nil, err
+	}
+
+	certs, err := ParseCertificates(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PartitionHeader{
+		Ticket:       ticket,
+		TMD:          tmd,
+		Certificates: certs,
+		H3Offset:     int64(ph.H3Off) << 2,
+		DataOffset:   int64(ph.DataOff) << 2,
+		DataSize:     int64(ph.DataSize) << 2,
+	}, nil
+}
@@ -0,0 +1,260 @@
+package wii
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// SignatureType identifies the algorithm used to sign a structure.
+type SignatureType uint32
+
+// The signature types used on Wii discs.
+const (
+	RSA4096 SignatureType = 0x10000 + iota
+	RSA2048
+	ECC
+)
+
+// size returns the size of the signature and of the padding that follows
+// it, which together with the type make up the signature block.
+func (t SignatureType) size() (int, int, error) {
+	switch t {
+	case RSA4096:
+		return 0x200, 0x3c, nil
+	case RSA2048:
+		return 0x100, 0x3c, nil
+	case ECC:
+		return 0x3c, 0x40, nil
+	}
+
+	return 0, 0, fmt.Errorf("%w %#x", ErrSignatureType, uint32(t))
+}
+
+// Signature is the signature found at the start of a signed structure.
+type Signature struct {
+	Type SignatureType
+	Data []byte
+}
+
+// parseSignature returns the signature at the start of b along with the
+// length of the signature block, including its padding.
+func parseSignature(b []byte) (Signature, int, error) {
+	if len(b) < 4 {
+		return Signature{}, 0, ErrShort
+	}
+
+	t := SignatureType(binary.BigEndian.Uint32(b))
+
+	size, padding, err := t.size()
+	if err != nil {
+		return Signature{}, 0, err
+	}
+
+	n := 4 + size + padding
+	if len(b) < n {
+		return Signature{}, 0, ErrShort
+	}
+
+	return Signature{Type: t, Data: b[4 : 4+size]}, n, nil
+}
+
+// Fakesigned reports whether the signature is blank, as used by the trucha
+// bug to pass off modified data signed over body as genuine.
+func (s Signature) Fakesigned(body []byte) bool {
+	sum := sha1.Sum(body) //nolint:gosec
+
+	return sum[0] == 0 && bytes.Count(s.Data, []byte{0}) == len(s.Data)
+}
+
+// KeyType identifies the type of public key held by a certificate.
+type KeyType uint32
+
+// The key types used on Wii discs.
+const (
+	KeyRSA4096 KeyType = iota
+	KeyRSA2048
+	KeyECC
+)
+
+func (t KeyType) size() (int, error) {
+	switch t {
+	case KeyRSA4096:
+		return 0x238, nil
+	case KeyRSA2048:
+		return 0x138, nil
+	case KeyECC:
+		return 0x78, nil
+	}
+
+	return 0, fmt.Errorf("%w %d", ErrKeyType, uint32(t))
+}
+
+type certificateHeader struct {
+	Issuer  [0x40]byte
+	KeyType KeyType
+	Name    [0x40]byte
+	KeyID   uint32
+}
+
+// Certificate is one certificate from the chain stored in each partition.
+type Certificate struct {
+	Signature Signature
+	Issuer    string
+	KeyType   KeyType
+	Name      string
+	KeyID     uint32
+	// PublicKey is nil for ECC keys.
+	PublicKey *rsa.PublicKey
+
+	body []byte
+}
+
+func parseCertificate(b []byte) (*Certificate, int, error) {
+	sig, n, err := parseSignature(b)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	h := new(certificateHeader)
+
+	size := binary.Size(h)
+	if len(b) < n+size {
+		return nil, 0, ErrShort
+	}
+
+	if err = binary.Read(bytes.NewReader(b[n:]), binary.BigEndian, h); err != nil {
+		return nil, 0, err
+	}
+
+	keySize, err := h.KeyType.size()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(b) < n+size+keySize {
+		return nil, 0, ErrShort
+	}
+
+	c := &Certificate{
+		Signature: sig,
+		Issuer:    cString(h.Issuer[:]),
+		KeyType:   h.KeyType,
+		Name:      cString(h.Name[:]),
+		KeyID:     h.KeyID,
+		body:      b[n : n+size+keySize],
+	}
+
+	if modulus := keySize - 0x38; h.KeyType != KeyECC {
+		key := b[n+size:]
+		c.PublicKey = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(key[:modulus]),
+			E: int(binary.BigEndian.Uint32(key[modulus:])),
+		}
+	}
+
+	return c, n + size + keySize, nil
+}
+
+// ParseCertificates parses the certificate chain held in b.
+func ParseCertificates(b []byte) ([]*Certificate, error) {
+	var certs []*Certificate
+
+	for len(b) > 0 {
+		c, n, err := parseCertificate(b)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, c)
+		b = b[n:]
+	}
+
+	return certs, nil
+}
+
+// ParseRootKey parses the public key of the root certificate authority from
+// b, which holds either just the 4096-bit modulus or the modulus followed by
+// the exponent.
+func ParseRootKey(b []byte) (*rsa.PublicKey, error) {
+	const modulus = 0x200
+
+	e := 0x10001
+
+	switch len(b) {
+	case modulus:
+	case modulus + 4:
+		e = int(binary.BigEndian.Uint32(b[modulus:]))
+	default:
+		return nil, ErrRootKey
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(b[:modulus]), E: e}, nil
+}
+
+// rootIssuer is the issuer of certificates signed by the root key.
+const rootIssuer = "Root"
+
+// verify checks sig over body was made by issuer, following the chain of
+// certificates back to the root. The final signature by the root is only
+// checked if root is not nil.
+func verify(issuer string, sig Signature, body []byte, certs []*Certificate, root *rsa.PublicKey) error {
+	// A blank signature can never be valid so there's no need to find
+	// the certificate first
+	if sig.Fakesigned(body) {
+		return ErrFakesigned
+	}
+
+	i := strings.LastIndexByte(issuer, '-')
+	if i < 0 {
+		if issuer != rootIssuer {
+			return fmt.Errorf("%w %s", ErrNoCertificate, issuer)
+		}
+
+		if root == nil {
+			return nil
+		}
+
+		return checkSignature(root, sig, body)
+	}
+
+	parent, name := issuer[:i], issuer[i+1:]
+
+	for _, c := range certs {
+		if c.Issuer != parent || c.Name != name {
+			continue
+		}
+
+		if c.PublicKey == nil {
+			return fmt.Errorf("%w %d for %s", ErrKeyType, uint32(c.KeyType), issuer)
+		}
+
+		if err := checkSignature(c.PublicKey, sig, body); err != nil {
+			return err
+		}
+
+		return verify(c.Issuer, c.Signature, c.body, certs, root)
+	}
+
+	return fmt.Errorf("%w %s", ErrNoCertificate, issuer)
+}
+
+func checkSignature(key *rsa.PublicKey, sig Signature, body []byte) error {
+	sum := sha1.Sum(body) //nolint:gosec
+	if rsa.VerifyPKCS1v15(key, crypto.SHA1, sum[:], sig.Data) == nil {
+		return nil
+	}
+
+	return ErrBadSignature
+}
+
+// Verify checks the signature on the certificate against the rest of the
+// chain in certs, and against root if it is not nil.
+func (c *Certificate) Verify(certs []*Certificate, root *rsa.PublicKey) error {
+	return verify(c.Issuer, c.Signature, c.body, certs, root)
+}
@@ -0,0 +1,138 @@
+package wii
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"encoding/binary"
+	"fmt"
+)
+
+// TicketSize is the size of the ticket at the start of each partition.
+const TicketSize = 0x2a4
+
+type ticketBody struct {
+	Issuer              [0x40]byte
+	ECDH                [0x3c]byte
+	Version             uint8
+	_                   [2]byte
+	TitleKey            [16]byte
+	_                   byte
+	TicketID            uint64
+	ConsoleID           uint32
+	TitleID             uint64
+	_                   uint16
+	TitleVersion        uint16
+	PermittedTitlesMask uint32
+	PermitMask          uint32
+	TitleExport         uint8
+	CommonKeyIndex      uint8
+	_                   [0x30]byte
+	ContentAccess       [0x40]byte
+	_                   [2]byte
+	Limits              [8][2]uint32
+}
+
+// Ticket grants access to a title and holds its encrypted title key.
+type Ticket struct {
+	Signature Signature
+	Issuer    string
+	Version   uint8
+	// TitleKey is encrypted with the common key selected by
+	// CommonKeyIndex.
+	TitleKey       [aes.BlockSize]byte
+	TicketID       uint64
+	ConsoleID      uint32
+	TitleID        TitleID
+	TitleVersion   uint16
+	CommonKeyIndex uint8
+
+	body []byte
+}
+
+// ParseTicket parses the ticket held in b.
+func ParseTicket(b []byte) (*Ticket, error) {
+	sig, n, err := parseSignature(b)
+	if err != nil {
+		return nil, err
+	}
+
+	tb := new(ticketBody)
+	if len(b) < n+binary.Size(tb) {
+		return nil, ErrShort
+	}
+
+	if err = binary.Read(bytes.NewReader(b[n:]), binary.BigEndian, tb); err != nil {
+		return nil, err
+	}
+
+	return &Ticket{
+		Signature:      sig,
+		Issuer:         cString(tb.Issuer[:]),
+		Version:        tb.Version,
+		TitleKey:       tb.TitleKey,
+		TicketID:       tb.TicketID,
+		ConsoleID:      tb.ConsoleID,
+		TitleID:        TitleID(tb.TitleID),
+		TitleVersion:   tb.TitleVersion,
+		CommonKeyIndex: tb.CommonKeyIndex,
+		body:           b[n : n+binary.Size(tb)],
+	}, nil
+}
+
+// Verify checks the signature on the ticket against the certificate chain in
+// certs, and against root if it is not nil. It returns ErrFakesigned if the
+// ticket has been signed using the trucha bug.
+func (t *Ticket) Verify(certs []*Certificate, root *rsa.PublicKey) error {
+	return verify(t.Issuer, t.Signature, t.body, certs, root)
+}
+
+// The common keys selected by Ticket.CommonKeyIndex.
+const (
+	CommonKeyRetail = iota
+	CommonKeyKorean
+	CommonKeyVWii
+)
+
+// CommonKeys holds the common keys used to encrypt title keys, in index
+// order.
+type CommonKeys [][aes.BlockSize]byte
+
+// ParseCommonKeys parses a key file holding the retail common key, optionally
+// followed by the Korean and vWii common keys.
+func ParseCommonKeys(b []byte) (CommonKeys, error) {
+	if len(b) == 0 || len(b)%aes.BlockSize != 0 || len(b) > (CommonKeyVWii+1)*aes.BlockSize {
+		return nil, ErrCommonKeys
+	}
+
+	keys := make(CommonKeys, len(b)/aes.BlockSize)
+	for i := range keys {
+		copy(keys[i][:], b[i*aes.BlockSize:])
+	}
+
+	return keys, nil
+}
+
+// DecryptTitleKey decrypts the title key in the ticket using the common key
+// it selects from keys.
+func (t *Ticket) DecryptTitleKey(keys CommonKeys) ([aes.BlockSize]byte, error) {
+	var key [aes.BlockSize]byte
+
+	if int(t.CommonKeyIndex) >= len(keys) {
+		return key, fmt.Errorf("%w %d", ErrNoCommonKey, t.CommonKeyIndex)
+	}
+
+	block, err := aes.NewCipher(keys[t.CommonKeyIndex][:])
+	if err != nil {
+		return key, err
+	}
+
+	// The IV is the title ID padded with zeroes
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv, uint64(t.TitleID))
+
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(key[:], t.TitleKey[:])
+
+	return key, nil
+}
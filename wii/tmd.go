@@ -0,0 +1,120 @@
+package wii
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+)
+
+type tmdHeader struct {
+	Issuer           [0x40]byte
+	Version          uint8
+	CACRLVersion     uint8
+	SignerCRLVersion uint8
+	VWii             uint8
+	SystemVersion    uint64
+	TitleID          uint64
+	TitleType        uint32
+	GroupID          uint16
+	_                uint16
+	Region           uint16
+	Ratings          [16]byte
+	_                [12]byte
+	IPCMask          [12]byte
+	_                [18]byte
+	AccessRights     uint32
+	TitleVersion     uint16
+	NumContents      uint16
+	BootIndex        uint16
+	_                uint16
+}
+
+// Content is a content record from a TMD.
+type Content struct {
+	ID    uint32
+	Index uint16
+	Type  uint16
+	Size  uint64
+	// Hash is the SHA-1 hash of the content. For a disc partition, the
+	// only content is the H3 table.
+	Hash [sha1.Size]byte
+}
+
+// TMD is the title metadata describing the contents of a title.
+type TMD struct {
+	Signature Signature
+	Issuer    string
+	Version   uint8
+	// SystemVersion is the title ID of the IOS the title runs under.
+	SystemVersion TitleID
+	TitleID       TitleID
+	TitleType     uint32
+	GroupID       uint16
+	Region        uint16
+	AccessRights  uint32
+	TitleVersion  uint16
+	BootIndex     uint16
+	Contents      []Content
+
+	body []byte
+}
+
+// ParseTMD parses the TMD held in b.
+func ParseTMD(b []byte) (*TMD, error) {
+	sig, n, err := parseSignature(b)
+	if err != nil {
+		return nil, err
+	}
+
+	h := new(tmdHeader)
+
+	size := binary.Size(h)
+	if len(b) < n+size {
+		return nil, ErrShort
+	}
+
+	r := bytes.NewReader(b[n:])
+	if err = binary.Read(r, binary.BigEndian, h); err != nil {
+		return nil, err
+	}
+
+	size += int(h.NumContents) * binary.Size(Content{})
+	if len(b) < n+size {
+		return nil, ErrShort
+	}
+
+	contents := make([]Content, h.NumContents)
+
+	if err = binary.Read(r, binary.BigEndian, contents); err != nil {
+		return nil, err
+	}
+
+	return &TMD{
+		Signature:     sig,
+		Issuer:        cString(h.Issuer[:]),
+		Version:       h.Version,
+		SystemVersion: TitleID(h.SystemVersion),
+		TitleID:       TitleID(h.TitleID),
+		TitleType:     h.TitleType,
+		GroupID:       h.GroupID,
+		Region:        h.Region,
+		AccessRights:  h.AccessRights,
+		TitleVersion:  h.TitleVersion,
+		BootIndex:     h.BootIndex,
+		Contents:      contents,
+		body:          b[n : n+size],
+	}, nil
+}
+
+// IOSVersion returns the version of IOS the title runs under.
+func (t *TMD) IOSVersion() uint32 {
+	return uint32(t.SystemVersion)
+}
+
+// Verify checks the signature on the TMD against the certificate chain in
+// certs, and against root if it is not nil. It returns ErrFakesigned if the
+// TMD has been signed using the trucha bug.
+func (t *TMD) Verify(certs []*Certificate, root *rsa.PublicKey) error {
+	return verify(t.Issuer, t.Signature, t.body, certs, root)
+}
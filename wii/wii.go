@@ -0,0 +1,54 @@
+// Package wii implements parsing of the structures found in Wii disc
+// partitions, such as the ticket, TMD and certificate chain, along with
+// verification of their signatures. It also handles the encryption of
+// partition sectors and the hash tree covering each cluster of them.
+package wii
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned when parsing or verifying partition structures.
+var (
+	ErrShort           = errors.New("wii: structure too short")
+	ErrSignatureType   = errors.New("wii: unknown signature type")
+	ErrKeyType         = errors.New("wii: unknown key type")
+	ErrNoCertificate   = errors.New("wii: no certificate for issuer")
+	ErrBadSignature    = errors.New("wii: signature doesn't match")
+	ErrFakesigned      = errors.New("wii: fakesigned")
+	ErrRootKey         = errors.New("wii: bad root key")
+	ErrPartitionHeader = errors.New("wii: bad partition header")
+	ErrCommonKeys      = errors.New("wii: bad common key file")
+	ErrNoCommonKey     = errors.New("wii: no common key for index")
+	ErrH3Size          = errors.New("wii: too many clusters for H3 table")
+
+	// ErrHash is matched by every HashError.
+	ErrHash = errors.New("wii: hash mismatch")
+)
+
+// TitleID identifies a title, such as a game or IOS.
+type TitleID uint64
+
+// String returns the title ID as two hexadecimal halves. If the lower half is
+// printable, as it is for games, it is shown as the four character game ID.
+func (t TitleID) String() string {
+	lower := []byte{byte(t >> 24), byte(t >> 16), byte(t >> 8), byte(t)}
+
+	for _, c := range lower {
+		if c < 0x20 || c > 0x7e {
+			return fmt.Sprintf("%08x-%08x", uint32(t>>32), uint32(t))
+		}
+	}
+
+	return fmt.Sprintf("%08x-%s", uint32(t>>32), lower)
+}
+
+func cString(b []byte) string {
+	if i := strings.IndexByte(string(b), 0); i >= 0 {
+		return string(b[:i])
+	}
+
+	return string(b)
+}
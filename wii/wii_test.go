@@ -0,0 +1,494 @@
+package wii_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec
+	"encoding/binary"
+	"testing"
+
+	"github.com/bodgit/rvz/wii"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	ticketTitleID = 0x1dc
+	tmdSize       = 0x1e4 + 0x24
+)
+
+type signer struct {
+	sigType wii.SignatureType
+	key     *rsa.PrivateKey
+}
+
+func (s signer) sigSize() int {
+	if s.sigType == wii.RSA4096 {
+		return 0x240
+	}
+
+	return 0x140
+}
+
+// sign fills in the signature block at the start of b over the rest of b.
+func (s signer) sign(tb testing.TB, b []byte) {
+	tb.Helper()
+
+	binary.BigEndian.PutUint32(b, uint32(s.sigType))
+
+	sum := sha1.Sum(b[s.sigSize():]) //nolint:gosec
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA1, sum[:])
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	copy(b[4:], sig)
+}
+
+func certificate(tb testing.TB, s signer, issuer, name string, key *rsa.PrivateKey) []byte {
+	tb.Helper()
+
+	b := make([]byte, s.sigSize()+0x88+0x138)
+	body := b[s.sigSize():]
+	copy(body, issuer)
+	binary.BigEndian.PutUint32(body[0x40:], uint32(wii.KeyRSA2048))
+	copy(body[0x44:], name)
+	key.N.FillBytes(body[0x88 : 0x88+0x100])
+	binary.BigEndian.PutUint32(body[0x188:], uint32(key.E))
+	s.sign(tb, b)
+
+	return b
+}
+
+type chain struct {
+	root         *rsa.PrivateKey
+	certs        []byte
+	ticket, tmd  signer
+	ticketIssuer string
+	tmdIssuer    string
+}
+
+func newChain(tb testing.TB) *chain {
+	tb.Helper()
+
+	keys := make([]*rsa.PrivateKey, 4)
+
+	for i := range keys {
+		bits := 2048
+		if i == 0 {
+			bits = 4096
+		}
+
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		keys[i] = key
+	}
+
+	ca := signer{wii.RSA2048, keys[1]}
+
+	var certs []byte
+	certs = append(certs, certificate(tb, signer{wii.RSA4096, keys[0]}, "Root", "CA00000001", keys[1])...)
+	certs = append(certs, certificate(tb, ca, "Root-CA00000001", "XS00000003", keys[2])...)
+	certs = append(certs, certificate(tb, ca, "Root-CA00000001", "CP00000004", keys[3])...)
+
+	return &chain{
+		root:         keys[0],
+		certs:        certs,
+		ticket:       signer{wii.RSA2048, keys[2]},
+		tmd:          signer{wii.RSA2048, keys[3]},
+		ticketIssuer: "Root-CA00000001-XS00000003",
+		tmdIssuer:    "Root-CA00000001-CP00000004",
+	}
+}
+
+func (c *chain) newTicket(tb testing.TB) []byte {
+	tb.Helper()
+
+	b := make([]byte, wii.TicketSize)
+	copy(b[0x140:], c.ticketIssuer)
+	copy(b[0x1bf:], "0123456789abcdef")
+	copy(b[ticketTitleID:], "\x00\x01\x00\x00RMCE")
+	b[0x1f1] = 1
+	c.ticket.sign(tb, b)
+
+	return b
+}
+
+func (c *chain) newTMD(tb testing.TB) []byte {
+	tb.Helper()
+
+	b := make([]byte, tmdSize)
+	copy(b[0x140:], c.tmdIssuer)
+	binary.BigEndian.PutUint64(b[0x184:], 0x0000000100000024)
+	copy(b[0x18c:], "\x00\x01\x00\x00RMCE")
+	binary.BigEndian.PutUint16(b[0x1de:], 1)
+	binary.BigEndian.PutUint64(b[0x1e4+8:], 0x18000)
+	b[0x1e4+0x10] = 0xaa
+	c.tmd.sign(tb, b)
+
+	return b
+}
+
+func rootKey(key *rsa.PrivateKey) []byte {
+	b := make([]byte, 0x204)
+	key.N.FillBytes(b[:0x200])
+	binary.BigEndian.PutUint32(b[0x200:], uint32(key.E))
+
+	return b
+}
+
+//nolint:funlen
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	c := newChain(t)
+
+	certs, err := wii.ParseCertificates(c.certs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := wii.ParseRootKey(rootKey(c.root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fakesigned := c.newTicket(t)
+	copy(fakesigned[4:0x104], make([]byte, 0x100))
+
+	for i := uint32(0); ; i++ {
+		binary.BigEndian.PutUint32(fakesigned[0x1f2:], i)
+
+		if sum := sha1.Sum(fakesigned[0x140:]); sum[0] == 0 { //nolint:gosec
+			break
+		}
+	}
+
+	tampered := c.newTicket(t)
+	tampered[ticketTitleID+7] = 'J'
+
+	tables := []struct {
+		name   string
+		ticket []byte
+		certs  []*wii.Certificate
+		root   *rsa.PublicKey
+		err    error
+	}{
+		{"without root", c.newTicket(t), certs, nil, nil},
+		{"with root", c.newTicket(t), certs, root, nil},
+		{"wrong root", c.newTicket(t), certs, &other.PublicKey, wii.ErrBadSignature},
+		{"tampered", tampered, certs, root, wii.ErrBadSignature},
+		{"no certificates", c.newTicket(t), nil, nil, wii.ErrNoCertificate},
+		{"fakesigned", fakesigned, certs, root, wii.ErrFakesigned},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			ticket, err := wii.ParseTicket(table.ticket)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.ErrorIs(t, ticket.Verify(table.certs, table.root), table.err)
+		})
+	}
+
+	tmd, err := wii.ParseTMD(c.newTMD(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, tmd.Verify(certs, root))
+
+	for _, cert := range certs {
+		assert.NoError(t, cert.Verify(certs, root))
+	}
+}
+
+func TestReadPartitionHeader(t *testing.T) {
+	t.Parallel()
+
+	c := newChain(t)
+
+	const (
+		offset     = 0x50000
+		tmdOffset  = 0x2c0
+		certOffset = 0x600
+	)
+
+	b := make([]byte, offset+0x20000)
+	p := b[offset:]
+	copy(p, c.newTicket(t))
+	binary.BigEndian.PutUint32(p[0x2a4:], tmdSize)
+	binary.BigEndian.PutUint32(p[0x2a8:], tmdOffset>>2)
+	binary.BigEndian.PutUint32(p[0x2ac:], uint32(len(c.certs)))
+	binary.BigEndian.PutUint32(p[0x2b0:], certOffset>>2)
+	binary.BigEndian.PutUint32(p[0x2b4:], 0x8000>>2)
+	binary.BigEndian.PutUint32(p[0x2b8:], 0x20000>>2)
+	binary.BigEndian.PutUint32(p[0x2bc:], 0x1000000>>2)
+	copy(p[tmdOffset:], c.newTMD(t))
+	copy(p[certOffset:], c.certs)
+
+	ph, err := wii.ReadPartitionHeader(bytes.NewReader(b), offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "00010000-RMCE", ph.Ticket.TitleID.String())
+	assert.Equal(t, []byte("0123456789abcdef"), ph.Ticket.TitleKey[:])
+	assert.Equal(t, uint8(1), ph.Ticket.CommonKeyIndex)
+	assert.Equal(t, uint32(36), ph.TMD.IOSVersion())
+	assert.Equal(t, wii.TitleID(0x0000000100000024), ph.TMD.SystemVersion)
+	assert.Equal(t, []wii.Content{{Size: 0x18000, Hash: [sha1.Size]byte{0xaa}}}, ph.TMD.Contents)
+	assert.Len(t, ph.Certificates, 3)
+	assert.Equal(t, int64(0x8000), ph.H3Offset)
+	assert.Equal(t, int64(0x20000), ph.DataOffset)
+	assert.Equal(t, int64(0x1000000), ph.DataSize)
+
+	assert.NoError(t, ph.Ticket.Verify(ph.Certificates, nil))
+	assert.NoError(t, ph.TMD.Verify(ph.Certificates, nil))
+
+	// Truncate the TMD
+	binary.BigEndian.PutUint32(p[0x2a4:], tmdSize-1)
+
+	_, err = wii.ReadPartitionHeader(bytes.NewReader(b), offset)
+	assert.ErrorIs(t, err, wii.ErrShort)
+}
+
+func TestParseRootKey(t *testing.T) {
+	t.Parallel()
+
+	key, err := wii.ParseRootKey(make([]byte, 0x200))
+	assert.NoError(t, err)
+	assert.Equal(t, 0x10001, key.E)
+
+	_, err = wii.ParseRootKey(make([]byte, 0x100))
+	assert.ErrorIs(t, err, wii.ErrRootKey)
+}
+
+func TestParseCertificates(t *testing.T) {
+	t.Parallel()
+
+	tables := []struct {
+		name    string
+		sigType wii.SignatureType
+		block   int // type, signature and padding
+		size    int
+	}{
+		{"RSA4096", wii.RSA4096, 0x240, 0x200},
+		{"RSA2048", wii.RSA2048, 0x140, 0x100},
+		{"ECC", wii.ECC, 0x80, 0x3c},
+	}
+
+	for _, table := range tables {
+		table := table
+
+		t.Run(table.name, func(t *testing.T) {
+			t.Parallel()
+
+			// A certificate with an ECC key followed by one with an RSA
+			// key, to check the signature block is the right size
+			b := make([]byte, table.block+0x88+0x78)
+			binary.BigEndian.PutUint32(b, uint32(table.sigType))
+			body := b[table.block:]
+			copy(body, "Root-CA00000001")
+			binary.BigEndian.PutUint32(body[0x40:], uint32(wii.KeyECC))
+			copy(body[0x44:], "MS00000002")
+
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b = append(b, certificate(t, signer{wii.RSA2048, key}, "Root-CA00000001", "XS00000003", key)...)
+
+			certs, err := wii.ParseCertificates(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !assert.Len(t, certs, 2) {
+				return
+			}
+
+			assert.Equal(t, table.sigType, certs[0].Signature.Type)
+			assert.Len(t, certs[0].Signature.Data, table.size)
+			assert.Equal(t, "Root-CA00000001", certs[0].Issuer)
+			assert.Equal(t, wii.KeyECC, certs[0].KeyType)
+			assert.Equal(t, "MS00000002", certs[0].Name)
+			assert.Nil(t, certs[0].PublicKey)
+
+			assert.Equal(t, "XS00000003", certs[1].Name)
+			assert.Equal(t, key.PublicKey.N, certs[1].PublicKey.N)
+		})
+	}
+}
+
+func TestTitleID(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "00010000-RMCE", wii.TitleID(0x00010000524d4345).String())
+	assert.Equal(t, "00000001-00000024", wii.TitleID(0x0000000100000024).String())
+}
+
+func TestDecryptTitleKey(t *testing.T) {
+	t.Parallel()
+
+	keys, err := wii.ParseCommonKeys(bytes.Repeat([]byte{0x11}, 3*aes.BlockSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, wii.TicketSize)
+	binary.BigEndian.PutUint32(b, uint32(wii.RSA2048))
+	copy(b[ticketTitleID:], "\x00\x01\x00\x00RMCE")
+	b[0x1f1] = wii.CommonKeyKorean
+
+	block, err := aes.NewCipher(keys[wii.CommonKeyKorean][:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, b[ticketTitleID:ticketTitleID+8])
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(b[0x1bf:0x1cf], []byte("0123456789abcdef"))
+
+	ticket, err := wii.ParseTicket(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ticket.DecryptTitleKey(keys)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("0123456789abcdef"), key[:])
+
+	_, err = ticket.DecryptTitleKey(keys[:1])
+	assert.ErrorIs(t, err, wii.ErrNoCommonKey)
+
+	_, err = wii.ParseCommonKeys(make([]byte, 20))
+	assert.ErrorIs(t, err, wii.ErrCommonKeys)
+}
+
+func testCluster() *wii.Cluster {
+	c := new(wii.Cluster)
+
+	for i := range c.Data {
+		for j := range c.Data[i] {
+			c.Data[i][j] = byte(i + j)
+		}
+	}
+
+	c.Hash()
+
+	return c
+}
+
+func TestClusterVerify(t *testing.T) {
+	t.Parallel()
+
+	tables := map[string]struct {
+		corrupt func(*wii.Cluster)
+		sector  int
+		level   int
+	}{
+		"data": {
+			corrupt: func(c *wii.Cluster) { c.Data[9][wii.BlockSize*3] ^= 0xff },
+			sector:  9,
+			level:   0,
+		},
+		"H1": {
+			corrupt: func(c *wii.Cluster) { c.Hashes[17][wii.H1Offset+sha1.Size] ^= 0xff },
+			sector:  17,
+			level:   1,
+		},
+		"H2": {
+			corrupt: func(c *wii.Cluster) { c.Hashes[63][wii.H2Offset] ^= 0xff },
+			sector:  63,
+			level:   2,
+		},
+	}
+
+	assert.NoError(t, testCluster().Verify())
+
+	for name, table := range tables {
+		name, table := name, table
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := testCluster()
+			table.corrupt(c)
+
+			err := c.Verify()
+			assert.ErrorIs(t, err, wii.ErrHash)
+			assert.Equal(t, &wii.HashError{Sector: table.sector, Level: table.level}, err)
+		})
+	}
+}
+
+func TestClusterHash(t *testing.T) {
+	t.Parallel()
+
+	c := testCluster()
+	h0 := c.Hashes[5].H0()
+	h1 := c.Hashes[5].H1()
+	h2 := c.Hashes[5].H2()
+
+	assert.Equal(t, sha1.Sum(c.Data[5][wii.BlockSize*2:wii.BlockSize*3]), h0[2])          //nolint:gosec
+	assert.Equal(t, sha1.Sum(c.Hashes[5][:wii.H0Size]), h1[5])                            //nolint:gosec
+	assert.Equal(t, sha1.Sum(c.Hashes[0][wii.H1Offset:wii.H1Offset+wii.H1Size]), h2[0])   //nolint:gosec
+	assert.Equal(t, sha1.Sum(c.Hashes[40][wii.H2Offset:wii.H2Offset+wii.H2Size]), c.H3()) //nolint:gosec
+}
+
+func TestEncryptSector(t *testing.T) {
+	t.Parallel()
+
+	block, err := aes.NewCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := testCluster()
+	b := make([]byte, wii.SectorSize)
+	c.EncryptSector(block, 3, b)
+
+	// Compare against the standard library
+	want := make([]byte, wii.SectorSize)
+	iv := want[wii.IVOffset : wii.IVOffset+aes.BlockSize]
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(want[:wii.HashBlockSize], c.Hashes[3][:])
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(want[wii.HashBlockSize:], c.Data[3][:])
+	assert.Equal(t, want, b)
+
+	d := new(wii.Cluster)
+	d.DecryptSector(block, 3, b)
+	assert.Equal(t, c.Hashes[3], d.Hashes[3])
+	assert.Equal(t, c.Data[3], d.Data[3])
+}
+
+func TestH3Table(t *testing.T) {
+	t.Parallel()
+
+	h3, err := wii.H3Table([][sha1.Size]byte{{1}, {2}})
+	assert.NoError(t, err)
+	assert.Len(t, h3, wii.H3Size)
+	assert.Equal(t, byte(2), h3[sha1.Size])
+	assert.Equal(t, make([]byte, wii.H3Size-2*sha1.Size), h3[2*sha1.Size:])
+
+	_, err = wii.H3Table(make([][sha1.Size]byte, wii.H3Size/sha1.Size+1))
+	assert.ErrorIs(t, err, wii.ErrH3Size)
+}
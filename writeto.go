@@ -0,0 +1,296 @@
+package rvz
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/bodgit/rvz/internal/util"
+)
+
+//nolint:gochecknoglobals
+var chunkPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, groupSize)
+
+		return &b
+	},
+}
+
+// A chunk is a piece of the disc image that can be decoded independently of
+// any other, either a raw data group or a cluster of a Wii partition.
+type chunk struct {
+	offset, size int64
+	rg           region
+	g            int   // group for raw data
+	sector       int   // first sector for partition data
+	skip         int64 // bytes at the start that aren't wanted
+}
+
+// chunks returns the chunks making up the disc image from offset onwards.
+// If offset isn't the start of a chunk then the first one skips up to it.
+func (r *Reader) chunks(offset int64) ([]chunk, error) {
+	var chunks []chunk
+
+	for _, rg := range r.regions() {
+		if rg.offset+rg.size <= offset {
+			continue
+		}
+
+		if rg.offset > offset {
+			return nil, r.decodeError(ErrNoRegion, offset, -1, -1)
+		}
+
+		if rg.raw < 0 {
+			sectors := int(rg.size / util.SectorSize)
+
+			for s := int((offset-rg.offset)/util.SectorSize) / clusters * clusters; s < sectors; s += clusters {
+				start := rg.offset + int64(s)*util.SectorSize
+				size := int64(min(clusters, sectors-s)) * util.SectorSize
+				chunks = append(chunks, chunk{offset: start, size: size, rg: rg, sector: s, skip: offset - start})
+				offset = start + size
+			}
+
+			continue
+		}
+
+		chunkSize := r.disc.chunkSize(false)
+
+		for i := (offset - rg.offset) / chunkSize; rg.offset+i*chunkSize < rg.offset+rg.size; i++ {
+			start := rg.offset + i*chunkSize
+
+			size := chunkSize
+			if remaining := rg.offset + rg.size - start; size > remaining {
+				size = remaining
+			}
+
+			chunks = append(chunks, chunk{
+				offset: start,
+				size:   size,
+				rg:     rg,
+				g:      int(rg.groupIndex) + int(i),
+				skip:   offset - start,
+			})
+			offset = start + size
+		}
+	}
+
+	if offset < int64(r.header.IsoFileSize) {
+		return nil, r.decodeError(ErrNoRegion, offset, -1, -1)
+	}
+
+	return chunks, nil
+}
+
+// group returns the index of the last group making up c.
+func (c chunk) group(r *Reader) int {
+	if c.rg.raw < 0 {
+		return int(c.rg.groupIndex) + (c.sector+int(c.size/util.SectorSize)-1)/r.disc.sectorsPerChunk()
+	}
+
+	return c.g
+}
+
+// decodeChunk decodes c into buf, returning the slice of buf holding it.
+func (r *Reader) decodeChunk(ctx context.Context, c chunk, buf []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.rg.raw < 0 {
+		pr := newPartReader(r, c.rg.part, c.rg.data)
+		pr.buf = buf[:0]
+		pr.sector = c.sector
+		pr.serial = true // the clusters themselves are decoded in parallel
+
+		if err := pr.read(ctx); err != nil {
+			return nil, err
+		}
+
+		return pr.buf, nil
+	}
+
+	rc, _, err := r.groupReader(c.g, c.offset, false)
+	if err != nil {
+		return nil, r.decodeError(err, c.offset, c.g, -1)
+	}
+
+	if _, err = io.ReadFull(rc, buf[:c.size]); err != nil {
+		rc.Close()
+
+		return nil, r.decodeError(err, c.offset, c.g, -1)
+	}
+
+	if err = rc.Close(); err != nil {
+		return nil, r.decodeError(err, c.offset, c.g, -1)
+	}
+
+	return buf[:c.size], nil
+}
+
+type job struct {
+	c    chunk
+	buf  *[]byte
+	b    []byte
+	err  error
+	done chan struct{}
+}
+
+func (r *Reader) finishRegion(ctx context.Context, w io.Writer) (n int64, err error) {
+	buf := make([]byte, 32*1024)
+
+	for r.r != nil {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		var m int
+
+		if m, err = r.Read(buf); err != nil {
+			if errors.Is(err, io.EOF) {
+				r.r = nil
+
+				return n, nil
+			}
+
+			return
+		}
+
+		var written int
+
+		written, err = w.Write(buf[:m])
+		n += int64(written)
+
+		switch {
+		case err != nil:
+			return
+		case written < m:
+			return n, io.ErrShortWrite
+		}
+	}
+
+	return
+}
+
+// WriteTo writes the rest of the disc image to w. Upcoming groups and
+// clusters are decoded in parallel while earlier ones are being written.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	return r.WriteToContext(r.ctx, w)
+}
+
+// WriteToContext is like WriteTo but also stops promptly once ctx is
+// cancelled, returning the context's error.
+//
+//nolint:cyclop,funlen
+func (r *Reader) WriteToContext(ctx context.Context, w io.Writer) (n int64, err error) {
+	if err = r.err(); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Also stop if the context the reader was created with is cancelled
+	if done := r.ctx.Done(); done != nil {
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	// Finish off any region that Read has already started
+	if n, err = r.finishRegion(ctx, w); err != nil {
+		return
+	}
+
+	chunks, err := r.chunks(r.offset)
+	if err != nil {
+		return n, err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, r.concurrency+1)
+		jobs = make(chan *job, r.concurrency+1)
+		stop = make(chan struct{})
+		cerr error
+	)
+
+	go func() {
+		defer close(jobs)
+
+		for _, c := range chunks {
+			select {
+			case sem <- struct{}{}:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				cerr = ctx.Err()
+
+				return
+			}
+
+			j := &job{
+				c:    c,
+				buf:  chunkPool.Get().(*[]byte), //nolint:forcetypeassert
+				done: make(chan struct{}),
+			}
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				defer close(j.done)
+
+				j.b, j.err = r.decodeChunk(ctx, j.c, *j.buf)
+			}()
+
+			jobs <- j
+		}
+	}()
+
+	for j := range jobs {
+		<-j.done
+
+		if err == nil {
+			if err = j.err; err == nil {
+				err = ctx.Err()
+			}
+
+			if err == nil {
+				var m int
+
+				b := j.b[j.c.skip:]
+
+				m, err = w.Write(b)
+				n += int64(m)
+				r.offset += int64(m)
+
+				if err == nil && m < len(b) {
+					err = io.ErrShortWrite
+				}
+			}
+
+			if err == nil {
+				r.report(j.c.offset+j.c.size, j.c.rg.partition(), j.c.group(r))
+			} else {
+				close(stop)
+			}
+		}
+
+		chunkPool.Put(j.buf)
+		<-sem
+	}
+
+	wg.Wait()
+
+	if err == nil {
+		err = cerr
+	}
+
+	return n, err
+}
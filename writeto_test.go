@@ -0,0 +1,240 @@
+package rvz_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bodgit/rvz"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingWriter struct {
+	n     int
+	short bool
+}
+
+var errWrite = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) <= w.n {
+		w.n -= len(p)
+
+		return len(p), nil
+	}
+
+	n := w.n
+	w.n = 0
+
+	if w.short {
+		return n, nil
+	}
+
+	return n, errWrite
+}
+
+//nolint:funlen
+func TestWriteTo(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+
+	b := (&testImage{
+		method:     methodZstd,
+		chunkSize:  sectorSize,
+		iso:        iso,
+		partitions: []testPartition{tp},
+	}).build(t)
+
+	for _, skip := range []int{0, 1, sectorSize + 123, int(tp.firstSector)*sectorSize + 5, len(iso)} {
+		skip := skip
+
+		t.Run(fmt.Sprintf("Skip%d", skip), func(t *testing.T) {
+			t.Parallel()
+
+			r, err := rvz.NewReader(bytes.NewReader(b), rvz.WithConcurrency(2))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out := make([]byte, skip)
+			if _, err = io.ReadFull(r, out); err != nil {
+				t.Fatal(err)
+			}
+
+			buf := bytes.NewBuffer(out)
+
+			n, err := r.WriteTo(buf)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(iso)-skip), n)
+			assert.True(t, bytes.Equal(iso, buf.Bytes()))
+
+			n, err = r.WriteTo(io.Discard)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), n)
+		})
+	}
+
+	for _, short := range []bool{false, true} {
+		short := short
+
+		t.Run(fmt.Sprintf("Fail%v", short), func(t *testing.T) {
+			t.Parallel()
+
+			r, err := rvz.NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := &failingWriter{n: int(tp.firstSector+3) * sectorSize, short: short}
+
+			n, err := r.WriteTo(w)
+			if short {
+				assert.ErrorIs(t, err, io.ErrShortWrite)
+			} else {
+				assert.ErrorIs(t, err, errWrite)
+			}
+
+			assert.Equal(t, int64(tp.firstSector+3)*sectorSize, n)
+		})
+	}
+}
+
+//nolint:funlen
+func TestContext(t *testing.T) {
+	t.Parallel()
+
+	iso, tp := testWiiISO(t, 140)
+
+	b := (&testImage{
+		method:     methodZstd,
+		chunkSize:  sectorSize,
+		iso:        iso,
+		partitions: []testPartition{tp},
+	}).build(t)
+
+	t.Run("Cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := rvz.NewReaderContext(ctx, bytes.NewReader(b))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		r, err := rvz.NewReaderContext(ctx, bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = io.ReadFull(r, make([]byte, sectorSize)); err != nil {
+			t.Fatal(err)
+		}
+
+		cancel()
+
+		_, err = r.Read(make([]byte, sectorSize))
+		assert.ErrorIs(t, err, context.Canceled)
+
+		_, err = r.WriteTo(io.Discard)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("WriteToContext", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		r, err := rvz.NewReader(bytes.NewReader(b), rvz.WithProgress(func(p rvz.Progress) {
+			if p.Partition == 0 {
+				cancel()
+			}
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := r.WriteToContext(ctx, io.Discard)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, n, int64(len(iso)))
+	})
+}
+
+func TestWriteToConcurrency(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu        sync.Mutex
+		open, max int
+	)
+
+	zstd := rvz.LookupDecompressor(methodZstd)
+	if zstd == nil {
+		t.Fatal("no zstd decompressor")
+	}
+
+	dcomp := func(b []byte, r io.Reader) (io.ReadCloser, error) {
+		rc, err := zstd(b, r)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		if open++; open > max {
+			max = open
+		}
+		mu.Unlock()
+
+		// Give the other goroutines a chance to open theirs
+		time.Sleep(time.Millisecond)
+
+		return &lockedReadCloser{countingReadCloser{rc, &open}, &mu}, nil
+	}
+
+	iso, tp := testWiiISO(t, 700)
+	b := (&testImage{
+		method:     methodZstd,
+		chunkSize:  sectorSize,
+		iso:        iso,
+		partitions: []testPartition{tp},
+	}).build(t)
+
+	const concurrency = 2
+
+	r, err := rvz.NewReader(bytes.NewReader(b), rvz.WithConcurrency(concurrency),
+		rvz.WithDecompressor(methodZstd, dcomp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.WriteTo(io.Discard)
+	assert.NoError(t, err)
+
+	// Each cluster being decoded only has one group open at a time
+	assert.LessOrEqual(t, max, concurrency+1)
+}
+
+type lockedReadCloser struct {
+	countingReadCloser
+	mu *sync.Mutex
+}
+
+func (rc *lockedReadCloser) Close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return rc.countingReadCloser.Close()
+}